@@ -23,6 +23,16 @@ func NewNodeIterable(node tree.Node) NodeIterable {
 		return index_variable_node{n: node}
 	case tree.NodePureAbstraction:
 		return pure_abstraction_node{n: node}
+	case tree.NodeLet:
+		return let_node{n: node}
+	case tree.NodeLetBinding:
+		return let_binding_node{n: node}
+	case tree.NodeLetRec:
+		return letrec_node{n: node}
+	case tree.NodeLetRecBindings:
+		return letrec_bindings_node{n: node}
+	case tree.NodeNumber:
+		return number_node{n: node}
 	}
 	panic("Unreachable")
 }
@@ -39,6 +49,16 @@ func NewNodeStringer(src source.SourceCode, t tree.Tree, node tree.Node) fmt.Str
 		return ToIndexVariableNode(t, node)
 	case tree.NodePureAbstraction:
 		return ToPureAbstractionNode(t, node)
+	case tree.NodeLet:
+		return ToLetNode(t, node)
+	case tree.NodeLetBinding:
+		return ToLetBindingNode(t, node)
+	case tree.NodeLetRec:
+		return ToLetRecNode(t, node)
+	case tree.NodeLetRecBindings:
+		return ToLetRecBindingsNode(t, node)
+	case tree.NodeNumber:
+		return ToNumberNode(t, node)
 	}
 	panic("Unreachable")
 }
@@ -64,6 +84,26 @@ type pure_abstraction_node struct {
 	n tree.Node
 }
 
+type let_node struct {
+	n tree.Node
+}
+
+type let_binding_node struct {
+	n tree.Node
+}
+
+type letrec_node struct {
+	n tree.Node
+}
+
+type letrec_bindings_node struct {
+	n tree.Node
+}
+
+type number_node struct {
+	n tree.Node
+}
+
 func ToNamedVariableNode(src source.SourceCode, tree tree.Tree, node tree.Node) named_variable_node {
 	return named_variable_node{
 		n:    node,
@@ -159,6 +199,115 @@ func (n pure_abstraction_node) Body() tree.NodeId {
 	return n.n.Lhs
 }
 
+func ToLetNode(tree tree.Tree, node tree.Node) let_node {
+	return let_node{
+		n: node,
+	}
+}
+
+func (n let_node) String() string {
+	return "let"
+}
+
+func (n let_node) Children() (tree.NodeId, tree.NodeId) {
+	return n.Binding(), n.Body()
+}
+
+func (n let_node) Binding() tree.NodeId {
+	return n.n.Lhs
+}
+
+func (n let_node) Body() tree.NodeId {
+	return n.n.Rhs
+}
+
+func ToLetBindingNode(tree tree.Tree, node tree.Node) let_binding_node {
+	return let_binding_node{
+		n: node,
+	}
+}
+
+func (n let_binding_node) String() string {
+	return "="
+}
+
+func (n let_binding_node) Children() (tree.NodeId, tree.NodeId) {
+	return n.Bound(), n.Value()
+}
+
+func (n let_binding_node) Bound() tree.NodeId {
+	return n.n.Lhs
+}
+
+func (n let_binding_node) Value() tree.NodeId {
+	return n.n.Rhs
+}
+
+func ToLetRecNode(tree tree.Tree, node tree.Node) letrec_node {
+	return letrec_node{
+		n: node,
+	}
+}
+
+func (n letrec_node) String() string {
+	return "letrec"
+}
+
+func (n letrec_node) Children() (tree.NodeId, tree.NodeId) {
+	return n.Bindings(), n.Body()
+}
+
+// Bindings are list of NodeLetRecBindings
+func (n letrec_node) Bindings() tree.NodeId {
+	return n.n.Lhs
+}
+
+func (n letrec_node) Body() tree.NodeId {
+	return n.n.Rhs
+}
+
+func ToLetRecBindingsNode(tree tree.Tree, node tree.Node) letrec_bindings_node {
+	return letrec_bindings_node{
+		n: node,
+	}
+}
+
+func (n letrec_bindings_node) String() string {
+	return "and"
+}
+
+func (n letrec_bindings_node) Children() (tree.NodeId, tree.NodeId) {
+	return n.Binding(), n.Next()
+}
+
+func (n letrec_bindings_node) Binding() tree.NodeId {
+	return n.n.Lhs
+}
+
+// Next is NodeNull for the last binding of the group
+func (n letrec_bindings_node) Next() tree.NodeId {
+	return n.n.Rhs
+}
+
+func ToNumberNode(tree tree.Tree, node tree.Node) number_node {
+	return number_node{
+		n: node,
+	}
+}
+
+func (n number_node) String() string {
+	return fmt.Sprintf("%d", n.n.Lhs)
+}
+
+func (n number_node) Children() (tree.NodeId, tree.NodeId) {
+	return tree.NodeNull, tree.NodeNull
+}
+
+// Value of numeric literal, it is up to desugaring stage to encode it
+func (n number_node) Value() int {
+	return int(n.n.Lhs)
+}
+
 type NodeAction = func(tree.Tree, tree.NodeId)
 
 func TraversePreorder(tree tree.Tree, root tree.NodeId, onEnter, onExit NodeAction) {
@@ -178,13 +327,82 @@ func traversePreorder(t tree.Tree, onEnter, onExit NodeAction, id tree.NodeId) {
 	traversePreorder(t, onEnter, onExit, rhs)
 }
 
+// TraverseFreeVariables calls action on every named variable of tree that is not bound
+// inside of it, with respect to scoping of abstractions, let and letrec
+func TraverseFreeVariables(src source.SourceCode, t tree.Tree, root tree.NodeId, action func(id tree.NodeId, name string)) {
+	bound := make(map[string]int)
+	name := func(id tree.NodeId) string {
+		return ToNamedVariableNode(src, t, t.Node(id)).Name
+	}
+
+	var aux func(tree.NodeId)
+	aux = func(id tree.NodeId) {
+		node := t.Node(id)
+		switch node.Tag {
+		case tree.NodeNamedVariable:
+			if n := name(id); bound[n] == 0 {
+				action(id, n)
+			}
+		case tree.NodeApplication:
+			aux(node.Lhs)
+			aux(node.Rhs)
+		case tree.NodeAbstraction:
+			abstraction := ToAbstractionNode(t, node)
+			n := name(abstraction.Bound())
+			bound[n]++
+			aux(abstraction.Body())
+			bound[n]--
+		case tree.NodeLet:
+			let := ToLetNode(t, node)
+			binding := ToLetBindingNode(t, t.Node(let.Binding()))
+			aux(binding.Value())
+			n := name(binding.Bound())
+			bound[n]++
+			aux(let.Body())
+			bound[n]--
+		case tree.NodeLetRec:
+			letrec := ToLetRecNode(t, node)
+			bindings := make([]let_binding_node, 0, 2)
+			for list := letrec.Bindings(); list != tree.NodeNull; {
+				l := ToLetRecBindingsNode(t, t.Node(list))
+				bindings = append(bindings, ToLetBindingNode(t, t.Node(l.Binding())))
+				list = l.Next()
+			}
+			for _, b := range bindings {
+				bound[name(b.Bound())]++
+			}
+			for _, b := range bindings {
+				aux(b.Value())
+			}
+			aux(letrec.Body())
+			for _, b := range bindings {
+				bound[name(b.Bound())]--
+			}
+		case tree.NodeIndexVariable, tree.NodePureAbstraction, tree.NodeNumber:
+			// closed
+		default:
+			panic("Unreachable")
+		}
+	}
+	aux(root)
+}
+
 func Print(src source.SourceCode, in_tree tree.Tree, root tree.NodeId) string {
+	return PrintWithHighlight(src, in_tree, root, tree.NodeNull, "", "")
+}
+
+// PrintWithHighlight is Print that puts subtree at highlight between open and close
+func PrintWithHighlight(src source.SourceCode, in_tree tree.Tree, root tree.NodeId, highlight tree.NodeId, open, close string) string {
 	str := strings.Builder{}
 	onEnter := func(t tree.Tree, id tree.NodeId) {
 		node := t.Node(id)
 		stringer_node := NewNodeStringer(src, t, node)
+		if id == highlight {
+			str.WriteString(open)
+		}
 		if node.Tag != tree.NodeIndexVariable &&
-			node.Tag != tree.NodeNamedVariable {
+			node.Tag != tree.NodeNamedVariable &&
+			node.Tag != tree.NodeNumber {
 			str.WriteByte('(')
 		} else {
 			str.WriteByte(' ')
@@ -194,11 +412,88 @@ func Print(src source.SourceCode, in_tree tree.Tree, root tree.NodeId) string {
 	onExit := func(t tree.Tree, id tree.NodeId) {
 		node := t.Node(id)
 		if node.Tag != tree.NodeIndexVariable &&
-			node.Tag != tree.NodeNamedVariable {
+			node.Tag != tree.NodeNamedVariable &&
+			node.Tag != tree.NodeNumber {
 			str.WriteByte(')')
 		}
+		if id == highlight {
+			str.WriteString(close)
+		}
 	}
 	TraversePreorder(in_tree, root, onEnter, onExit)
 
 	return str.String()
 }
+
+// PrintNamed prints named tree in source syntax, that both strict and non-strict parsers accept
+func PrintNamed(src source.SourceCode, in_tree tree.Tree, root tree.NodeId) string {
+	return PrintNamedWithHighlight(src, in_tree, root, tree.NodeNull, "", "")
+}
+
+// PrintNamedWithHighlight is PrintNamed that puts subtree at highlight between open and close
+func PrintNamedWithHighlight(src source.SourceCode, in_tree tree.Tree, root tree.NodeId, highlight tree.NodeId, open, close string) string {
+	str := strings.Builder{}
+	name := func(id tree.NodeId) string {
+		return ToNamedVariableNode(src, in_tree, in_tree.Node(id)).Name
+	}
+
+	var aux func(id tree.NodeId)
+	aux = func(id tree.NodeId) {
+		node := in_tree.Node(id)
+		if id == highlight {
+			str.WriteString(open)
+			defer str.WriteString(close)
+		}
+		switch node.Tag {
+		case tree.NodeNamedVariable:
+			str.WriteString(name(id))
+		case tree.NodeNumber:
+			str.WriteString(ToNumberNode(in_tree, node).String())
+		case tree.NodeApplication:
+			application := ToApplicationNode(in_tree, node)
+			// body of abstraction or let extends as far right as possible in non-strict grammar
+			lhs := in_tree.Node(application.Lhs())
+			open := lhs.Tag == tree.NodeAbstraction || lhs.Tag == tree.NodeLet || lhs.Tag == tree.NodeLetRec
+			str.WriteByte('(')
+			if open {
+				str.WriteByte('(')
+			}
+			aux(application.Lhs())
+			if open {
+				str.WriteByte(')')
+			}
+			str.WriteByte(' ')
+			aux(application.Rhs())
+			str.WriteByte(')')
+		case tree.NodeAbstraction:
+			abstraction := ToAbstractionNode(in_tree, node)
+			str.WriteString("λ" + name(abstraction.Bound()) + ".")
+			aux(abstraction.Body())
+		case tree.NodeLet:
+			let := ToLetNode(in_tree, node)
+			binding := ToLetBindingNode(in_tree, in_tree.Node(let.Binding()))
+			str.WriteString("let " + name(binding.Bound()) + " = ")
+			aux(binding.Value())
+			str.WriteString(" in ")
+			aux(let.Body())
+		case tree.NodeLetRec:
+			letrec := ToLetRecNode(in_tree, node)
+			str.WriteString("letrec ")
+			for list := letrec.Bindings(); list != tree.NodeNull; {
+				l := ToLetRecBindingsNode(in_tree, in_tree.Node(list))
+				binding := ToLetBindingNode(in_tree, in_tree.Node(l.Binding()))
+				str.WriteString(name(binding.Bound()) + " = ")
+				aux(binding.Value())
+				if list = l.Next(); list != tree.NodeNull {
+					str.WriteString(" and ")
+				}
+			}
+			str.WriteString(" in ")
+			aux(letrec.Body())
+		default:
+			panic("Unreachable")
+		}
+	}
+	aux(root)
+	return str.String()
+}
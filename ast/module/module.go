@@ -0,0 +1,53 @@
+// Module is a source file made of imports, top level definitions and optional main expression:
+//
+//	import "bool.lc"
+//	import "num.lc" as N
+//	def Id = λx.x
+//	def K = λx y.x
+//	K Id
+//
+// All nodes of definitions and main live in one tree
+package module
+
+import (
+	"lambda/ast/tree"
+	"lambda/syntax/source"
+)
+
+// Import makes definitions of other module visible, qualified ones are referred to
+// with alias and dot, like N.Succ
+type Import struct {
+	Token source.TokenId // import keyword
+	Path  string         // relative to directory of importing module
+	Alias string         // empty for unqualified import
+}
+
+type Definition struct {
+	Name  string
+	Token source.TokenId // def keyword
+	Bound tree.NodeId    // named variable with defined name
+	Value tree.NodeId
+	Start source.TokenId // first token of the value
+	End   source.TokenId // first token after the value
+}
+
+type Module struct {
+	Imports     []Import
+	Tree        tree.Tree
+	Definitions []Definition
+	Main        tree.NodeId    // NodeNull when module has no main expression
+	MainStart   source.TokenId // first token of main expression, or Eof token
+}
+
+func (m Module) Lookup(name string) (Definition, bool) {
+	for _, d := range m.Definitions {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+func (m Module) HasMain() bool {
+	return m.Main != tree.NodeNull
+}
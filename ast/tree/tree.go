@@ -16,6 +16,11 @@ const (
 	NodeAbstraction
 	NodeIndexVariable
 	NodePureAbstraction
+	NodeLet
+	NodeLetBinding
+	NodeLetRec
+	NodeLetRecBindings
+	NodeNumber
 	NodeMax
 )
 
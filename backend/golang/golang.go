@@ -0,0 +1,248 @@
+// Package golang translates de bruijn tree into standalone Go program that evaluates it
+package golang
+
+import (
+	"fmt"
+	"go/format"
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/middle/numeral"
+	"strings"
+)
+
+type Options struct {
+	// print numeral result as integer
+	DecodeNumerals bool
+	Encoding       numeral.Encoding
+}
+
+// Generate returns source of Go program that prints normal form of term at root in de bruijn
+// form, the same way as interpreter does. Abstractions become Go closures over universal
+// value type and arguments become lazy shared thunks, so result is the one of normal order.
+// Bound variable is named after level of its abstraction, so index n at depth d is x<d-1-n>
+func Generate(t tree.Tree, root tree.NodeId, options Options) ([]byte, error) {
+	str := strings.Builder{}
+	var aux func(id tree.NodeId, depth int)
+	aux = func(id tree.NodeId, depth int) {
+		node := t.Node(id)
+		switch node.Tag {
+		case tree.NodeIndexVariable:
+			index := ast.ToIndexVariableNode(t, node).Index()
+			if index < depth {
+				fmt.Fprintf(&str, "x%d.force()", depth-1-index)
+			} else {
+				fmt.Fprintf(&str, "free(%d)", index-depth)
+			}
+		case tree.NodePureAbstraction:
+			fmt.Fprintf(&str, "function(func(x%d *thunk) value {\nreturn ", depth)
+			aux(ast.ToPureAbstractionNode(t, node).Body(), depth+1)
+			str.WriteString("\n})")
+		case tree.NodeApplication:
+			app := ast.ToApplicationNode(t, node)
+			str.WriteString("apply(")
+			aux(app.Lhs(), depth)
+			str.WriteString(", ")
+			// variable is passed as is, its thunk is shared already
+			rhs := t.Node(app.Rhs())
+			if rhs.Tag == tree.NodeIndexVariable && ast.ToIndexVariableNode(t, rhs).Index() < depth {
+				fmt.Fprintf(&str, "x%d", depth-1-ast.ToIndexVariableNode(t, rhs).Index())
+			} else {
+				str.WriteString("delay(func() value {\nreturn ")
+				aux(app.Rhs(), depth)
+				str.WriteString("\n})")
+			}
+			str.WriteString(")")
+		default:
+			panic("unreachable")
+		}
+	}
+	aux(root, 0)
+
+	decode := "nil"
+	if options.DecodeNumerals {
+		decode = "decode_" + options.Encoding.String()
+	}
+	program := fmt.Sprintf(runtime, decode, str.String())
+	return format.Source([]byte(program))
+}
+
+// runtime of generated program, formatted with decoder of numerals and the term
+const runtime = `// Code generated by lambda build. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type value interface{}
+
+type function func(argument *thunk) value
+
+// variable applied to arguments, bound one has level of its abstraction
+type neutral struct {
+	level int
+	free  bool
+	args  []*thunk
+}
+
+type thunk struct {
+	compute func() value
+	value   value
+}
+
+func delay(compute func() value) *thunk {
+	return &thunk{compute: compute}
+}
+
+func (t *thunk) force() value {
+	if t.value == nil {
+		t.value = t.compute()
+		t.compute = nil
+	}
+	return t.value
+}
+
+func free(index int) value {
+	return neutral{level: index, free: true}
+}
+
+func apply(f value, argument *thunk) value {
+	switch f := f.(type) {
+	case function:
+		return f(argument)
+	case neutral:
+		args := make([]*thunk, len(f.args), len(f.args)+1)
+		copy(args, f.args)
+		f.args = append(args, argument)
+		return f
+	}
+	panic("unreachable")
+}
+
+// normal form in de bruijn form
+type term struct {
+	abstraction bool
+	index       int
+	// body of abstraction is lhs
+	lhs, rhs *term
+}
+
+func quote(v value, depth int) *term {
+	switch v := v.(type) {
+	case function:
+		variable := &thunk{value: neutral{level: depth}}
+		return &term{abstraction: true, lhs: quote(v(variable), depth+1)}
+	case neutral:
+		index := depth - 1 - v.level
+		if v.free {
+			index = v.level + depth
+		}
+		t := &term{index: index}
+		for _, arg := range v.args {
+			t = &term{lhs: t, rhs: quote(arg.force(), depth)}
+		}
+		return t
+	}
+	panic("unreachable")
+}
+
+func print(str *strings.Builder, t *term) {
+	switch {
+	case t.abstraction:
+		str.WriteString("(λ")
+		print(str, t.lhs)
+		str.WriteString(")")
+	case t.lhs != nil:
+		str.WriteString("(")
+		print(str, t.lhs)
+		print(str, t.rhs)
+		str.WriteString(")")
+	default:
+		str.WriteString(" " + strconv.Itoa(t.index))
+	}
+}
+
+// body of λ.λ.body, or nil
+func body(t *term) *term {
+	for i := 0; i < 2; i++ {
+		if t == nil || !t.abstraction {
+			return nil
+		}
+		t = t.lhs
+	}
+	return t
+}
+
+func is_index(t *term, i int) bool {
+	return t != nil && !t.abstraction && t.lhs == nil && t.index == i
+}
+
+func is_application(t *term) bool {
+	return t != nil && !t.abstraction && t.lhs != nil
+}
+
+func decode_church(t *term) (n int, ok bool) {
+	for t = body(t); is_application(t); t = t.rhs {
+		if !is_index(t.lhs, 1) {
+			return 0, false
+		}
+		n++
+	}
+	return n, is_index(t, 0)
+}
+
+func decode_scott(t *term) (n int, ok bool) {
+	for ; ; n++ {
+		b := body(t)
+		if is_index(b, 0) {
+			return n, true
+		}
+		if !is_application(b) || !is_index(b.lhs, 1) {
+			return 0, false
+		}
+		t = b.rhs
+	}
+}
+
+func decode_binary(t *term) (n int, ok bool) {
+	for weight := 1; ; weight *= 2 {
+		b := body(t)
+		if is_index(b, 1) {
+			return n, true
+		}
+		if !is_application(b) || !is_application(b.lhs) || !is_index(b.lhs.lhs, 0) {
+			return 0, false
+		}
+		switch bit := body(b.lhs.rhs); {
+		case is_index(bit, 1):
+			n += weight
+		case is_index(bit, 0):
+		default:
+			return 0, false
+		}
+		t = b.rhs
+	}
+}
+
+var decode func(t *term) (int, bool) = %s
+
+func main() {
+	result := quote(program(), 0)
+	if decode != nil {
+		if n, ok := decode(result); ok {
+			fmt.Println(n)
+			return
+		}
+	}
+	str := strings.Builder{}
+	print(&str, result)
+	fmt.Println(strings.TrimSpace(str.String()))
+}
+
+func program() value {
+	return %s
+}
+`
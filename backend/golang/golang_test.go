@@ -0,0 +1,115 @@
+package golang
+
+import (
+	"context"
+	"lambda/ast/ast"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"lambda/middle/numeral"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(test *testing.T) {
+	_, t := evaltest.DeBruijnWithNumerals(test, `λx.(x (λy.y) x z)`, numeral.Church)
+	code, err := Generate(t, t.RootId(), Options{})
+	if err != nil {
+		test.Fatal(err)
+	}
+	expected := []string{
+		"func(x0 *thunk) value",
+		"func(x1 *thunk) value",
+		"x1.force()",
+		"apply(apply(apply(x0.force(), delay(",
+		"), x0), delay(",
+		"free(0)",
+		"var decode func(t *term) (int, bool) = nil",
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(code), e) {
+			test.Errorf("Expected %q in\n%s", e, code)
+		}
+	}
+
+	code, err = Generate(t, t.RootId(), Options{DecodeNumerals: true, Encoding: numeral.Binary})
+	if err != nil {
+		test.Fatal(err)
+	}
+	if e := "= decode_binary"; !strings.Contains(string(code), e) {
+		test.Errorf("Expected %q in\n%s", e, code)
+	}
+}
+
+func TestGenerateRun(test *testing.T) {
+	if testing.Short() {
+		test.Skip("builds Go programs")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		test.Skip("go is not found")
+	}
+	texts := [...]string{
+		`x`,
+		`λx.((λy.y) x)`,
+		`λx y.(x (λz.z) y q)`,
+		`((λx.λy.(y x)) (λz.z))`,
+		`((λx.λy.y) ((λx.(x x)) (λx.(x x))))`,
+		evaltest.Prelude + `(Pred 3)`,
+		evaltest.Prelude + `(Fact 3)`,
+	}
+	dir := test.TempDir()
+	for i, text := range texts {
+		source_code, t := evaltest.DeBruijnWithNumerals(test, text, numeral.Church)
+		expected_tree, err := eval.Eval(context.Background(), t.Clone(), t.RootId(), eval.EvalOptions{})
+		if err != nil {
+			test.Fatal(err)
+		}
+		expected := strings.TrimSpace(ast.Print(source_code, expected_tree, expected_tree.RootId()))
+
+		code, err := Generate(t, t.RootId(), Options{})
+		if err != nil {
+			test.Fatal(err)
+		}
+		file := filepath.Join(dir, "main.go")
+		if err := os.WriteFile(file, code, 0o644); err != nil {
+			test.Fatal(err)
+		}
+		output, err := exec.Command("go", "run", file).CombinedOutput()
+		if err != nil {
+			test.Fatalf("%d: %v\n%s", i, err, output)
+		}
+		if got := strings.TrimSpace(string(output)); got != expected {
+			test.Errorf("%d: expected %s got %s", i, expected, got)
+		}
+	}
+}
+
+func TestGenerateRunNumerals(test *testing.T) {
+	if testing.Short() {
+		test.Skip("builds Go programs")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		test.Skip("go is not found")
+	}
+	dir := test.TempDir()
+	for _, e := range [...]numeral.Encoding{numeral.Church, numeral.Scott, numeral.Binary} {
+		_, t := evaltest.DeBruijnWithNumerals(test, `6`, e)
+		code, err := Generate(t, t.RootId(), Options{DecodeNumerals: true, Encoding: e})
+		if err != nil {
+			test.Fatal(err)
+		}
+		file := filepath.Join(dir, "main.go")
+		if err := os.WriteFile(file, code, 0o644); err != nil {
+			test.Fatal(err)
+		}
+		output, err := exec.Command("go", "run", file).CombinedOutput()
+		if err != nil {
+			test.Fatalf("%s: %v\n%s", e, err, output)
+		}
+		if got := strings.TrimSpace(string(output)); got != "6" {
+			test.Errorf("%s: expected 6 got %s", e, got)
+		}
+	}
+}
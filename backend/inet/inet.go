@@ -0,0 +1,331 @@
+// Package inet is experimental optimal reduction of lambda terms: term is translated into
+// sharing graph of Lamping and Gonthier, Abadi and Lévy, that is interaction net with
+// fans, croissants and brackets, and the net is reduced lazily while it is read back
+package inet
+
+import (
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/syntax/source"
+)
+
+type node_kind uint8
+
+const (
+	node_dead node_kind = iota
+	// the interface of the net, its port is connected to the term
+	node_root
+	// ports: principal is the abstraction itself, then body and variable
+	node_lambda
+	// ports: principal is the function, then argument and result
+	node_application
+	// ports: principal, then two auxiliary ones, shares term at principal between them
+	node_fan
+	// ports: principal and auxiliary, nodes that pass from principal side lose a level
+	node_croissant
+	// ports: principal and auxiliary, nodes that pass from principal side gain a level
+	node_bracket
+	// erases term at principal
+	node_eraser
+	// free variable of the term
+	node_free
+)
+
+var node_arities = [...]int{
+	node_dead:        0,
+	node_root:        1,
+	node_lambda:      3,
+	node_application: 3,
+	node_fan:         3,
+	node_croissant:   2,
+	node_bracket:     2,
+	node_eraser:      1,
+	node_free:        1,
+}
+
+type node_id int32
+
+// port is slot of a node, 0 is principal
+type port struct {
+	node node_id
+	slot int8
+}
+
+type node struct {
+	kind  node_kind
+	level int
+	// free index for free variable
+	index int
+	// token of abstraction or free variable
+	token source.TokenId
+	ports [3]port
+}
+
+// Net is sharing graph of a term
+type Net struct {
+	nodes []node
+	free  []node_id
+	root  node_id
+	live  int
+	stats Stats
+}
+
+// Stats counts interactions that reduction has done
+type Stats struct {
+	// β-reductions, interactions of abstraction and application
+	Beta int
+	// interactions of fans, croissants, brackets and erasers
+	Fan, Croissant, Bracket, Eraser int
+}
+
+// Interactions is the total number of interactions
+func (s Stats) Interactions() int {
+	return s.Beta + s.Fan + s.Croissant + s.Bracket + s.Eraser
+}
+
+func (n *Net) add(kind node_kind, level int) node_id {
+	n.live++
+	x := node{kind: kind, level: level, token: source.TokenInvalid}
+	if len(n.free) > 0 {
+		id := n.free[len(n.free)-1]
+		n.free = n.free[:len(n.free)-1]
+		n.nodes[id] = x
+		return id
+	}
+	n.nodes = append(n.nodes, x)
+	return node_id(len(n.nodes) - 1)
+}
+
+func (n *Net) remove(id node_id) {
+	n.live--
+	n.nodes[id].kind = node_dead
+	n.free = append(n.free, id)
+}
+
+func (n *Net) peer(p port) port {
+	return n.nodes[p.node].ports[p.slot]
+}
+
+func (n *Net) link(a, b port) {
+	n.nodes[a.node].ports[a.slot] = b
+	n.nodes[b.node].ports[b.slot] = a
+}
+
+// Nodes is the number of live nodes of the net
+func (n *Net) Nodes() int {
+	return n.live
+}
+
+// Stats counts interactions done so far
+func (n *Net) Stats() Stats {
+	return n.stats
+}
+
+// NewNet translates term at root of de bruijn tree. Term at level l is translated so:
+// variable is croissant of level l, abstraction is λ of level l with its occurrences
+// shared by fans of levels where they meet, and application is @ of level l with
+// argument at level l+1, free variables of which are put through brackets of level l
+func NewNet(t tree.Tree, root tree.NodeId) *Net {
+	n := &Net{}
+	free_tokens := make(map[int]source.TokenId)
+	// variables are keyed by level of their abstraction, free ones by ^index
+	var aux func(id tree.NodeId, level, depth int) (port, map[int]port)
+	aux = func(id tree.NodeId, level, depth int) (port, map[int]port) {
+		x := t.Node(id)
+		switch x.Tag {
+		case tree.NodeIndexVariable:
+			index := ast.ToIndexVariableNode(t, x).Index()
+			key := depth - 1 - index
+			if index >= depth {
+				key = ^(index - depth)
+				free_tokens[key] = x.Token
+			}
+			c := n.add(node_croissant, level)
+			n.nodes[c].token = x.Token
+			return port{c, 1}, map[int]port{key: {c, 0}}
+		case tree.NodePureAbstraction:
+			body, vars := aux(ast.ToPureAbstractionNode(t, x).Body(), level, depth+1)
+			l := n.add(node_lambda, level)
+			n.nodes[l].token = x.Token
+			n.link(port{l, 1}, body)
+			if v, ok := vars[depth]; ok {
+				n.link(port{l, 2}, v)
+				delete(vars, depth)
+			} else {
+				n.link(port{l, 2}, port{n.add(node_eraser, 0), 0})
+			}
+			return port{l, 0}, vars
+		case tree.NodeApplication:
+			lhs, rhs := ast.ToApplicationNode(t, x).Children()
+			function, vars := aux(lhs, level, depth)
+			argument, argument_vars := aux(rhs, level+1, depth)
+			a := n.add(node_application, level)
+			n.link(port{a, 0}, function)
+			n.link(port{a, 1}, argument)
+			for key, v := range argument_vars {
+				b := n.add(node_bracket, level)
+				n.link(port{b, 1}, v)
+				v = port{b, 0}
+				if w, ok := vars[key]; ok {
+					f := n.add(node_fan, level)
+					n.link(port{f, 1}, w)
+					n.link(port{f, 2}, v)
+					v = port{f, 0}
+				}
+				vars[key] = v
+			}
+			return port{a, 2}, vars
+		default:
+			panic("unreachable")
+		}
+	}
+
+	term, vars := aux(root, 0, 0)
+	n.root = n.add(node_root, 0)
+	n.link(port{n.root, 0}, term)
+	for key, v := range vars {
+		f := n.add(node_free, 0)
+		n.nodes[f].index = ^key
+		n.nodes[f].token = free_tokens[key]
+		n.link(port{f, 0}, v)
+	}
+	return n
+}
+
+func is_control(kind node_kind) bool {
+	return kind == node_fan || kind == node_croissant || kind == node_bracket
+}
+
+// active tells whether nodes that are connected by principal ports interact
+func (n *Net) active(a, b node_id) bool {
+	ka, kb := n.nodes[a].kind, n.nodes[b].kind
+	if ka == node_root || kb == node_root {
+		return false
+	}
+	// variable applied to arguments is normal
+	if ka == node_free && kb != node_eraser && !is_control(kb) ||
+		kb == node_free && ka != node_eraser && !is_control(ka) {
+		return false
+	}
+	return true
+}
+
+// interact rewrites active pair
+func (n *Net) interact(a, b node_id) {
+	x, y := n.nodes[a], n.nodes[b]
+	switch {
+	case (x.kind == node_lambda || x.kind == node_application) && x.level != y.level &&
+		(y.kind == node_lambda || y.kind == node_application):
+		panic("unreachable")
+	case x.kind == node_application && y.kind == node_lambda:
+		n.beta(b, a)
+	case x.kind == node_lambda && y.kind == node_application:
+		n.beta(a, b)
+	case x.kind == node_eraser:
+		n.stats.Eraser++
+		n.commute(a, b, y.level)
+	case y.kind == node_eraser:
+		n.stats.Eraser++
+		n.commute(b, a, x.level)
+	case is_control(x.kind) && x.kind == y.kind && x.level == y.level:
+		n.count(x.kind)
+		n.annihilate(a, b)
+	case is_control(x.kind) && (y.kind == node_free || x.level < y.level):
+		n.count(x.kind)
+		n.commute(a, b, n.lift(x, y.level))
+	case is_control(y.kind) && (x.kind == node_free || y.level < x.level):
+		n.count(y.kind)
+		n.commute(b, a, n.lift(y, x.level))
+	default:
+		panic("unreachable")
+	}
+}
+
+func (n *Net) count(kind node_kind) {
+	switch kind {
+	case node_fan:
+		n.stats.Fan++
+	case node_croissant:
+		n.stats.Croissant++
+	case node_bracket:
+		n.stats.Bracket++
+	}
+}
+
+// lift is level of node that passes through control node c from its principal side
+func (n *Net) lift(c node, level int) int {
+	switch c.kind {
+	case node_croissant:
+		return level - 1
+	case node_bracket:
+		return level + 1
+	}
+	return level
+}
+
+// beta connects result of application to body of abstraction and argument to variable
+func (n *Net) beta(lambda, application node_id) {
+	n.stats.Beta++
+	n.join(port{application, 2}, port{lambda, 1})
+	n.join(port{application, 1}, port{lambda, 2})
+	n.remove(lambda)
+	n.remove(application)
+}
+
+// annihilate connects auxiliary ports of equal control nodes pairwise
+func (n *Net) annihilate(a, b node_id) {
+	for slot := int8(1); slot < int8(node_arities[n.nodes[a].kind]); slot++ {
+		n.join(port{a, slot}, port{b, slot})
+	}
+	n.remove(a)
+	n.remove(b)
+}
+
+// join connects whatever is at ports p and q of nodes that are removed
+func (n *Net) join(p, q port) {
+	pp, pq := n.peer(p), n.peer(q)
+	if pp == q {
+		return
+	}
+	n.link(pp, pq)
+}
+
+// commute moves node a through node b: b is copied for every auxiliary port of a, with
+// given level, and a is copied for every auxiliary port of b
+func (n *Net) commute(a, b node_id, level int) {
+	x, y := n.nodes[a], n.nodes[b]
+	ka, kb := node_arities[x.kind]-1, node_arities[y.kind]-1
+	var bs, as [2]node_id
+	for i := 0; i < ka; i++ {
+		bs[i] = n.add(y.kind, level)
+		n.nodes[bs[i]].index = y.index
+		n.nodes[bs[i]].token = y.token
+	}
+	for j := 0; j < kb; j++ {
+		as[j] = n.add(x.kind, x.level)
+		n.nodes[as[j]].token = x.token
+	}
+	// port that stands for p after the rewrite
+	stand_in := func(p port) port {
+		switch {
+		case p.node == a && p.slot > 0:
+			return port{bs[p.slot-1], 0}
+		case p.node == b && p.slot > 0:
+			return port{as[p.slot-1], 0}
+		}
+		return p
+	}
+	for i := 0; i < ka; i++ {
+		n.link(port{bs[i], 0}, stand_in(x.ports[i+1]))
+	}
+	for j := 0; j < kb; j++ {
+		n.link(port{as[j], 0}, stand_in(y.ports[j+1]))
+	}
+	for i := 0; i < ka; i++ {
+		for j := 0; j < kb; j++ {
+			n.link(port{bs[i], int8(j + 1)}, port{as[j], int8(i + 1)})
+		}
+	}
+	n.remove(a)
+	n.remove(b)
+}
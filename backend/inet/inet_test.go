@@ -0,0 +1,110 @@
+package inet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda/ast/ast"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"math/rand"
+	"testing"
+)
+
+func TestNormalize(test *testing.T) {
+	texts := []string{
+		`x`,
+		`λx.x`,
+		`λx.((λy.y) x)`,
+		`λx y.(x (λz.z) y q)`,
+		`((λx.λy.(y x)) (λz.z))`,
+		`((λx.λy.y) ((λx.(x x)) (λx.(x x))))`,
+		`λx.((λy.λz.(y z)) x)`,
+		`((λx.(x x)) (λy.y))`,
+		`((λx.(x x)) (λy.(y a)))`,
+		`λf.((λx.(x x)) (f a))`,
+		evaltest.Prelude + `(Two Two)`,
+		evaltest.Prelude + `(Three Two)`,
+		evaltest.Prelude + `(Two Two Two)`,
+		evaltest.Prelude + `(Pred Three)`,
+		evaltest.Prelude + `(Mult Three Two)`,
+		evaltest.Prelude + `(Fact Three)`,
+	}
+	evaltest.Compare(test, Eval, eval.EvalOptions{}, texts...)
+}
+
+func random_term(rng *rand.Rand, depth, size int) string {
+	if size <= 1 || rng.Intn(4) == 0 {
+		if depth > 0 && rng.Intn(8) != 0 {
+			return fmt.Sprintf("v%d", rng.Intn(depth))
+		}
+		return "free"
+	}
+	if rng.Intn(2) == 0 {
+		return fmt.Sprintf("λv%d.%s", depth, random_term(rng, depth+1, size-1))
+	}
+	lhs := 1 + rng.Intn(size-1)
+	return fmt.Sprintf("(%s %s)", random_term(rng, depth, lhs), random_term(rng, depth, size-lhs))
+}
+
+func TestNormalizeRandom(test *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		text := random_term(rng, 0, 10+rng.Intn(50))
+		src, t := evaltest.DeBruijn(test, text)
+		expected_tree, err := eval.Eval(context.Background(), t.Clone(), t.RootId(), eval.EvalOptions{MaxReductions: 1000})
+		if err != nil {
+			continue
+		}
+		expected := ast.Print(src, expected_tree, expected_tree.RootId())
+		result, err := NewNet(t, t.RootId()).Normalize(context.Background(), eval.EvalOptions{})
+		if err != nil {
+			test.Fatal(err)
+		}
+		if got := ast.Print(src, result, result.RootId()); got != expected {
+			test.Fatalf("%s: expected %s got %s", text, expected, got)
+		}
+	}
+}
+
+func TestStats(test *testing.T) {
+	_, t := evaltest.DeBruijn(test, `
+    let Two = λf x.(f (f x)) in
+    ((Two Two Two) (λx.x) a)`)
+	tracer := evaltest.Counter{}
+	_, err := eval.Eval(context.Background(), t, t.RootId(), eval.EvalOptions{Tracer: &tracer})
+	if err != nil {
+		test.Fatal(err)
+	}
+	n := NewNet(t, t.RootId())
+	if _, err := n.Normalize(context.Background(), eval.EvalOptions{}); err != nil {
+		test.Fatal(err)
+	}
+	stats := n.Stats()
+	if stats.Beta >= tracer.Reductions {
+		test.Errorf("Expected fewer β-reductions than %d, got %d", tracer.Reductions, stats.Beta)
+	}
+	if stats.Interactions() <= stats.Beta || stats.Fan == 0 {
+		test.Errorf("Unexpected interactions %+v", stats)
+	}
+}
+
+func TestEvalLimits(test *testing.T) {
+	_, t := evaltest.DeBruijn(test, `((λx.((x x) x)) (λx.((x x) x)))`)
+	options := [...]eval.EvalOptions{
+		{MaxReductions: 1000},
+		{MaxNodes: 1000},
+		{Timeout: 1},
+	}
+	limits := [...]eval.Limit{eval.LimitReductions, eval.LimitNodes, eval.LimitTime}
+	for i, o := range options {
+		result, err := Eval(context.Background(), t, t.RootId(), o)
+		var limit *eval.LimitError
+		if !errors.As(err, &limit) || limit.Limit != limits[i] {
+			test.Fatalf("Expected %s limit, got %v", limits[i], err)
+		}
+		if result.Count() != 0 || limit.Partial.Count() != 0 {
+			test.Errorf("Expected no partial result")
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package inet
+
+import (
+	"context"
+	"lambda/ast/tree"
+	"lambda/eval"
+	"reflect"
+)
+
+// level of path context is the stack of choices made at fans of that level
+type level interface{}
+
+// fan of the level is entered at auxiliary port side
+type choice struct {
+	side int8
+	rest level
+}
+
+// levels that bracket has merged
+type pair struct {
+	lhs, rhs level
+}
+
+// level that croissant has added
+type mark struct{}
+
+// levels is context of path in the net, it is never modified in place. Missing levels are empty
+type levels []level
+
+func (c levels) get(i int) level {
+	if i < len(c) {
+		return c[i]
+	}
+	return nil
+}
+
+func (c levels) set(i int, l level) levels {
+	size := len(c)
+	if i >= size {
+		size = i + 1
+	}
+	result := make(levels, size)
+	copy(result, c)
+	result[i] = l
+	return result
+}
+
+func (c levels) insert(i int, l level) levels {
+	if i >= len(c) {
+		return c.set(i, l)
+	}
+	result := make(levels, 0, len(c)+1)
+	result = append(result, c[:i]...)
+	result = append(result, l)
+	return append(result, c[i:]...)
+}
+
+func (c levels) delete(i int) levels {
+	if i >= len(c) {
+		return c
+	}
+	result := make(levels, 0, len(c)-1)
+	result = append(result, c[:i]...)
+	return append(result, c[i+1:]...)
+}
+
+type binder struct {
+	lambda node_id
+	// context of path at the abstraction
+	levels levels
+}
+
+type reader struct {
+	net    *Net
+	budget *eval.Budget
+	nodes  []tree.Node
+	// abstractions above the term that is read
+	binders []binder
+}
+
+// Normalize reduces the net to normal form and reads it back. Only interactions that
+// the path from the root to a variable meets are done, so reduction is lazy and shared
+// parts are reduced once. The same part of the net can be read many times, as read
+// back term is unshared. Limits of options are checked, MaxReductions bounds β-reductions
+// and MaxNodes bounds live nodes of the net, Partial term of LimitError is empty
+func (n *Net) Normalize(ctx context.Context, options eval.EvalOptions) (result tree.Tree, err error) {
+	b := eval.NewBudget(ctx, options)
+	err = b.Run(func() {
+		result = n.read_back(b)
+	})
+	if err == nil && options.Tracer != nil {
+		options.Tracer.OnDone(b.Reductions(), result)
+	}
+	return result, err
+}
+
+func (n *Net) read_back(b *eval.Budget) tree.Tree {
+	r := reader{net: n, budget: b}
+	// wire from the root is never active, so read does not fail
+	root, _ := r.read(port{n.root, 0}, nil)
+	return tree.NewTree(root, r.nodes)
+}
+
+func (r *reader) add_node(node tree.Node) tree.NodeId {
+	r.nodes = append(r.nodes, node)
+	return tree.NodeId(len(r.nodes) - 1)
+}
+
+// interact rewrites active pair and checks limits
+func (r *reader) interact(a, b node_id) {
+	n := r.net
+	ka, kb := n.nodes[a].kind, n.nodes[b].kind
+	if (ka == node_lambda || ka == node_application) && (kb == node_lambda || kb == node_application) {
+		r.budget.Reduce()
+	} else {
+		r.budget.Tick()
+	}
+	n.interact(a, b)
+	r.budget.Nodes(n.live)
+}
+
+// read follows the wire from port of a node that is already read and reads term there,
+// c is context of the path. Active pair on the way is reduced, and if the node of from
+// is gone with it, ok is false and caller has to look at its own port again
+func (r *reader) read(from port, c levels) (id tree.NodeId, ok bool) {
+	n := r.net
+	for {
+		to := n.peer(from)
+		if from.slot == 0 && to.slot == 0 && n.active(from.node, to.node) {
+			r.interact(from.node, to.node)
+			return tree.NodeNull, false
+		}
+
+		x := n.nodes[to.node]
+		switch {
+		case x.kind == node_lambda && to.slot == 0:
+			r.binders = append(r.binders, binder{lambda: to.node, levels: c})
+			body, ok := r.read(port{to.node, 1}, c)
+			r.binders = r.binders[:len(r.binders)-1]
+			if !ok {
+				continue
+			}
+			return r.add_node(tree.Node{
+				Tag:   tree.NodePureAbstraction,
+				Token: x.token,
+				Lhs:   body,
+				Rhs:   tree.NodeNull}), true
+		case x.kind == node_lambda && to.slot == 2:
+			return r.variable(to.node, c), true
+		case x.kind == node_application && to.slot == 2:
+			function, ok := r.read(port{to.node, 0}, c)
+			if !ok {
+				continue
+			}
+			argument, ok := r.read(port{to.node, 1}, c)
+			if !ok {
+				continue
+			}
+			return r.add_node(tree.Node{
+				Tag:   tree.NodeApplication,
+				Token: x.token,
+				Lhs:   function,
+				Rhs:   argument}), true
+		case x.kind == node_free:
+			return r.add_node(tree.Node{
+				Tag:   tree.NodeIndexVariable,
+				Token: x.token,
+				Lhs:   tree.NodeId(x.index + len(r.binders)),
+				Rhs:   tree.NodeNull}), true
+		case x.kind == node_fan && to.slot == 0:
+			top, is_choice := c.get(x.level).(choice)
+			if !is_choice {
+				panic("unreachable")
+			}
+			id, ok := r.read(port{to.node, top.side}, c.set(x.level, top.rest))
+			if !ok {
+				continue
+			}
+			return id, true
+		case x.kind == node_fan:
+			id, ok := r.read(port{to.node, 0}, c.set(x.level, choice{side: to.slot, rest: c.get(x.level)}))
+			if !ok {
+				continue
+			}
+			return id, true
+		case x.kind == node_croissant || x.kind == node_bracket:
+			next, next_levels := port{to.node, 0}, raise(x, c)
+			if to.slot == 0 {
+				next, next_levels = port{to.node, 1}, lower(x, c)
+			}
+			id, ok := r.read(next, next_levels)
+			if !ok {
+				continue
+			}
+			return id, true
+		default:
+			panic("unreachable")
+		}
+	}
+}
+
+// raise is context of path that goes through croissant or bracket from auxiliary port to principal
+func raise(x node, c levels) levels {
+	if x.kind == node_croissant {
+		return c.insert(x.level, mark{})
+	}
+	return c.delete(x.level+1).set(x.level, pair{lhs: c.get(x.level), rhs: c.get(x.level + 1)})
+}
+
+// lower is context of path that goes through croissant or bracket from principal port to auxiliary
+func lower(x node, c levels) levels {
+	if x.kind == node_croissant {
+		return c.delete(x.level)
+	}
+	p, _ := c.get(x.level).(pair)
+	return c.set(x.level, p.lhs).insert(x.level+1, p.rhs)
+}
+
+// variable finds abstraction that binds variable at lambda, path gets to it with context c.
+// Copies of the abstraction are told apart by fans of levels below its own, that could
+// duplicate it, so the binder is the innermost one read from the same node with the same
+// context below its level. Operations on these levels on the path from the abstraction
+// to the variable cancel each other
+func (r *reader) variable(lambda node_id, c levels) tree.NodeId {
+	l := r.net.nodes[lambda].level
+	for i := len(r.binders) - 1; i >= 0; i-- {
+		b := r.binders[i]
+		if b.lambda == lambda && equal_below(b.levels, c, l) {
+			return r.add_node(tree.Node{
+				Tag:   tree.NodeIndexVariable,
+				Token: r.net.nodes[lambda].token,
+				Lhs:   tree.NodeId(len(r.binders) - 1 - i),
+				Rhs:   tree.NodeNull})
+		}
+	}
+	panic("unreachable")
+}
+
+// equal_below tells whether contexts are the same at levels below l
+func equal_below(a, b levels, l int) bool {
+	for i := 0; i < l; i++ {
+		if !reflect.DeepEqual(a.get(i), b.get(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Eval translates term into net and normalizes it, it is a backend of eval.EvalWhole,
+// where reductions are β-reductions of the net
+func Eval(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options eval.EvalOptions) (tree.Tree, error) {
+	return eval.EvalWhole(ctx, options, func(b *eval.Budget) tree.Tree {
+		return NewNet(in_tree, root).read_back(b)
+	})
+}
@@ -0,0 +1,253 @@
+package ski
+
+import (
+	"context"
+	"lambda/ast/tree"
+	"lambda/eval"
+	"lambda/syntax/source"
+)
+
+type node_kind uint8
+
+const (
+	node_combinator node_kind = iota
+	// free variable or fresh variable of readback
+	node_variable
+	node_application
+	// node is replaced by lhs, it is left when redex reduces to one of its arguments
+	node_indirection
+)
+
+// node of combinator graph, application is overwritten with the result of reduction,
+// so every shared subterm is reduced once
+type node struct {
+	kind       node_kind
+	combinator Combinator
+	// free index, or level of fresh variable
+	index int
+	free  bool
+	token source.TokenId
+	// term is in normal form already
+	normal   bool
+	lhs, rhs *node
+}
+
+func follow(n *node) *node {
+	for n.kind == node_indirection {
+		n = n.lhs
+	}
+	return n
+}
+
+type reducer struct {
+	budget *eval.Budget
+	// allocated nodes of the graph
+	nodes int
+	// applications from the top of term being reduced to its head
+	spine []*node
+}
+
+func (r *reducer) alloc(n node) *node {
+	r.nodes++
+	r.budget.Nodes(r.nodes)
+	return &n
+}
+
+// load copies term into graph, keeping its sharing
+func (r *reducer) load(t *Term, loaded map[*Term]*node) *node {
+	if n, ok := loaded[t]; ok {
+		return n
+	}
+	var n *node
+	switch t.Kind {
+	case KindCombinator:
+		n = r.alloc(node{kind: node_combinator, combinator: t.Combinator})
+	case KindFree:
+		n = r.alloc(node{kind: node_variable, index: t.Index, free: true, token: t.Token})
+	case KindApplication:
+		n = r.alloc(node{kind: node_application, lhs: r.load(t.Lhs, loaded), rhs: r.load(t.Rhs, loaded)})
+	default:
+		panic("unreachable")
+	}
+	loaded[t] = n
+	return n
+}
+
+// instantiate builds right hand side of combinator definition with given arguments
+func (r *reducer) instantiate(body *Term, args []*node) *node {
+	if body.Kind == kind_bound {
+		return args[body.Index]
+	}
+	return r.alloc(node{
+		kind: node_application,
+		lhs:  r.instantiate(body.Lhs, args),
+		rhs:  r.instantiate(body.Rhs, args)})
+}
+
+// whnf reduces n until its head is variable or combinator that lacks arguments.
+// Spine is unwound to the head, and the application that gives combinator all its
+// arguments is overwritten with the result
+func (r *reducer) whnf(n *node) *node {
+	base := len(r.spine)
+	args := make([]*node, 0, 4)
+	for current := n; ; {
+		current = follow(current)
+		if current.kind == node_application {
+			r.spine = append(r.spine, current)
+			current = current.lhs
+			continue
+		}
+		if current.kind != node_combinator {
+			break
+		}
+		arity := current.combinator.Arity()
+		if len(r.spine)-base < arity {
+			break
+		}
+
+		r.budget.Reduce()
+		args = args[:0]
+		for i := 1; i <= arity; i++ {
+			args = append(args, r.spine[len(r.spine)-i].rhs)
+		}
+		redex := r.spine[len(r.spine)-arity]
+		body := combinator_bodies[current.combinator]
+		if body.Kind == kind_bound {
+			*redex = node{kind: node_indirection, lhs: args[body.Index]}
+		} else {
+			*redex = *r.instantiate(body, args)
+		}
+		r.spine = r.spine[:len(r.spine)-arity]
+		current = redex
+	}
+	r.spine = r.spine[:base]
+	return follow(n)
+}
+
+// unwind returns head of n and its arguments, first one first
+func unwind(n *node) (*node, []*node) {
+	args := make([]*node, 0)
+	for n = follow(n); n.kind == node_application; n = follow(n.lhs) {
+		args = append(args, n.rhs)
+	}
+	for i, j := 0, len(args)-1; i < j; i, j = i+1, j-1 {
+		args[i], args[j] = args[j], args[i]
+	}
+	return n, args
+}
+
+// normalize reduces n and all its arguments
+func (r *reducer) normalize(n *node) *node {
+	n = r.whnf(n)
+	if n.normal {
+		return n
+	}
+	_, args := unwind(n)
+	for _, arg := range args {
+		r.normalize(arg)
+	}
+	n.normal = true
+	return n
+}
+
+// Reduce reduces t to combinator normal form, where every combinator lacks arguments
+// and every argument is normal. It is weak reduction: S K is normal although it is λx.λy.y.
+// Limits of options are checked, MaxNodes bounds allocated nodes of the graph, Partial
+// term of LimitError is empty
+func Reduce(ctx context.Context, t *Term, options eval.EvalOptions) (result *Term, err error) {
+	r := reducer{budget: eval.NewBudget(ctx, options)}
+	err = r.budget.Run(func() {
+		n := r.normalize(r.load(t, make(map[*Term]*node)))
+
+		terms := make(map[*node]*Term)
+		var aux func(n *node) *Term
+		aux = func(n *node) *Term {
+			n = follow(n)
+			if t, ok := terms[n]; ok {
+				return t
+			}
+			var t *Term
+			switch n.kind {
+			case node_combinator:
+				t = combinator(n.combinator)
+			case node_variable:
+				t = &Term{Kind: KindFree, Index: n.index, Token: n.token}
+			case node_application:
+				t = application(aux(n.lhs), aux(n.rhs))
+			default:
+				panic("unreachable")
+			}
+			terms[n] = t
+			return t
+		}
+		result = aux(n)
+	})
+	return result, err
+}
+
+// Normalize reduces t and reads lambda normal form back: combinator that lacks arguments
+// is applied to fresh variable and becomes abstraction over its result, so reduction goes
+// on under abstractions without substitution. Limits are handled like in Reduce,
+// Tracer gets only OnDone
+func Normalize(ctx context.Context, t *Term, options eval.EvalOptions) (result tree.Tree, err error) {
+	b := eval.NewBudget(ctx, options)
+	err = b.Run(func() {
+		result = normal_form(b, t)
+	})
+	if err == nil && options.Tracer != nil {
+		options.Tracer.OnDone(b.Reductions(), result)
+	}
+	return result, err
+}
+
+func normal_form(b *eval.Budget, t *Term) tree.Tree {
+	r := reducer{budget: b}
+	nodes := make([]tree.Node, 0)
+	root := r.readback(&nodes, r.load(t, make(map[*Term]*node)), 0)
+	return tree.NewTree(root, nodes)
+}
+
+// readback quotes n to normal form, depth is the number of abstractions above it
+func (r *reducer) readback(nodes *[]tree.Node, n *node, depth int) tree.NodeId {
+	add_node := func(node tree.Node) tree.NodeId {
+		*nodes = append(*nodes, node)
+		return tree.NodeId(len(*nodes) - 1)
+	}
+
+	head, args := unwind(r.whnf(n))
+	if head.kind == node_combinator {
+		variable := r.alloc(node{kind: node_variable, index: depth, token: source.TokenInvalid})
+		body := r.readback(nodes, r.alloc(node{kind: node_application, lhs: n, rhs: variable}), depth+1)
+		return add_node(tree.Node{
+			Tag:   tree.NodePureAbstraction,
+			Token: source.TokenInvalid,
+			Lhs:   body,
+			Rhs:   tree.NodeNull})
+	}
+
+	index := depth - 1 - head.index
+	if head.free {
+		index = head.index + depth
+	}
+	id := add_node(tree.Node{
+		Tag:   tree.NodeIndexVariable,
+		Token: head.token,
+		Lhs:   tree.NodeId(index),
+		Rhs:   tree.NodeNull})
+	for _, arg := range args {
+		argument := r.readback(nodes, arg, depth)
+		id = add_node(tree.Node{
+			Tag:   tree.NodeApplication,
+			Token: source.TokenInvalid,
+			Lhs:   id,
+			Rhs:   argument})
+	}
+	return id
+}
+
+// Eval compiles term with Turner's combinators and normalizes it, it is a backend of eval.EvalWhole
+func Eval(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options eval.EvalOptions) (tree.Tree, error) {
+	return eval.EvalWhole(ctx, options, func(b *eval.Budget) tree.Tree {
+		return normal_form(b, Compile(in_tree, root, BasisTurner))
+	})
+}
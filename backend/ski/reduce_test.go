@@ -0,0 +1,85 @@
+package ski
+
+import (
+	"context"
+	"errors"
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+)
+
+func TestReduce(test *testing.T) {
+	cases := [...]struct {
+		text, expected string
+	}{
+		{`((λx y z.(x z (y z))) (λx y.x) (λx y.x) a)`, `a`},
+		{`λx y.y`, `K I`},
+		{`((λx.λy.(y x)) (λz.z))`, `C I I`},
+		{`((λf x.(f (f x))) (λf x.(f (f x))) g a)`, `g (g (g (g a)))`},
+		{`((λx.λy.y) ((λx.(x x)) (λx.(x x))))`, `I`},
+	}
+	for _, c := range cases {
+		src, t := evaltest.DeBruijn(test, c.text)
+		result, err := Reduce(context.Background(), Compile(t, t.RootId(), BasisTurner), eval.EvalOptions{})
+		if err != nil {
+			test.Fatal(err)
+		}
+		if got := Print(src, result); got != c.expected {
+			test.Errorf("%s: expected %s got %s", c.text, c.expected, got)
+		}
+	}
+}
+
+func TestNormalize(test *testing.T) {
+	texts := []string{
+		`x`,
+		`λx.((λy.y) x)`,
+		`λx y.(x (λz.z) y q)`,
+		`((λx.λy.(y x)) (λz.z))`,
+		`((λx.λy.y) ((λx.(x x)) (λx.(x x))))`,
+		`λx.((λy.λz.(y z)) x)`,
+		evaltest.Prelude + `(Pred Three)`,
+		evaltest.Prelude + `(Fact Three)`,
+	}
+	for basis := BasisSKI; basis <= BasisTurner; basis++ {
+		basis := basis
+		test.Run(basis.String(), func(test *testing.T) {
+			evaltest.Compare(test, func(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options eval.EvalOptions) (tree.Tree, error) {
+				return Normalize(ctx, Compile(in_tree, root, basis), options)
+			}, eval.EvalOptions{}, texts...)
+		})
+	}
+}
+
+func TestNormalizeWithEta(test *testing.T) {
+	src, t := evaltest.DeBruijn(test, `λx.((λy.λz.(y z)) x)`)
+	result, err := Normalize(context.Background(), CompileWithEta(t, t.RootId(), BasisTurner), eval.EvalOptions{})
+	if err != nil {
+		test.Fatal(err)
+	}
+	if got := ast.Print(src, result, result.RootId()); got != `(λ 0)` {
+		test.Errorf("Expected η-normal form, got %s", got)
+	}
+}
+
+func TestEvalLimits(test *testing.T) {
+	_, t := evaltest.DeBruijn(test, `((λx.(x x)) (λx.(x x)))`)
+	options := [...]eval.EvalOptions{
+		{MaxReductions: 1000},
+		{MaxNodes: 1000},
+		{Timeout: 1},
+	}
+	limits := [...]eval.Limit{eval.LimitReductions, eval.LimitNodes, eval.LimitTime}
+	for i, o := range options {
+		result, err := Eval(context.Background(), t, t.RootId(), o)
+		var limit *eval.LimitError
+		if !errors.As(err, &limit) || limit.Limit != limits[i] {
+			test.Fatalf("Expected %s limit, got %v", limits[i], err)
+		}
+		if result.Count() != 0 || limit.Partial.Count() != 0 {
+			test.Errorf("Expected no partial result")
+		}
+	}
+}
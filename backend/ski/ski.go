@@ -0,0 +1,373 @@
+// Package ski translates de bruijn tree into combinator terms by bracket abstraction
+// and reduces them as a graph, so evaluation needs no variables at all
+package ski
+
+import (
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/syntax/source"
+	"strconv"
+	"strings"
+)
+
+type Combinator uint8
+
+const (
+	// S f g x = f x (g x)
+	S Combinator = iota
+	// K x y = x
+	K
+	// I x = x
+	I
+	// B f g x = f (g x)
+	B
+	// C f g x = f x g
+	C
+	// W f x = f x x
+	W
+	// S' c f g x = c (f x) (g x)
+	SPrime
+	// B* c f g x = c (f (g x))
+	BStar
+	// C' c f g x = c (f x) g
+	CPrime
+)
+
+var combinator_names = [...]string{
+	S:      "S",
+	K:      "K",
+	I:      "I",
+	B:      "B",
+	C:      "C",
+	W:      "W",
+	SPrime: "S'",
+	BStar:  "B*",
+	CPrime: "C'",
+}
+
+var combinator_arities = [...]int{
+	S:      3,
+	K:      2,
+	I:      1,
+	B:      3,
+	C:      3,
+	W:      2,
+	SPrime: 4,
+	BStar:  4,
+	CPrime: 4,
+}
+
+func (c Combinator) String() string {
+	return combinator_names[c]
+}
+
+// Arity is the number of arguments that combinator needs to be reduced
+func (c Combinator) Arity() int {
+	return combinator_arities[c]
+}
+
+// Basis is the set of combinators that bracket abstraction produces
+type Basis int
+
+const (
+	// S, K and I only
+	BasisSKI Basis = iota
+	// B, C, K, W and I, S is expressed as B (B W) (B B C)
+	BasisBCKW
+	// Turner's optimizations: S, K, I, B, C, S', B* and C'
+	BasisTurner
+)
+
+var basis_names = [...]string{
+	BasisSKI:    "ski",
+	BasisBCKW:   "bckw",
+	BasisTurner: "turner",
+}
+
+func (b Basis) String() string {
+	return basis_names[b]
+}
+
+func ParseBasis(name string) (Basis, bool) {
+	for b, n := range basis_names {
+		if n == name {
+			return Basis(b), true
+		}
+	}
+	return BasisSKI, false
+}
+
+type Kind uint8
+
+const (
+	KindCombinator Kind = iota
+	KindFree
+	KindApplication
+	// variable bound by abstraction at level Index, it never appears in compiled term
+	kind_bound
+)
+
+// Term is combinator term, subterms may be shared
+type Term struct {
+	Kind       Kind
+	Combinator Combinator
+	// index of free variable
+	Index int
+	// token of free variable
+	Token    source.TokenId
+	Lhs, Rhs *Term
+}
+
+func combinator(c Combinator) *Term {
+	return &Term{Kind: KindCombinator, Combinator: c, Token: source.TokenInvalid}
+}
+
+func application(lhs, rhs *Term) *Term {
+	return &Term{Kind: KindApplication, Token: source.TokenInvalid, Lhs: lhs, Rhs: rhs}
+}
+
+func applications(terms ...*Term) *Term {
+	t := terms[0]
+	for _, rhs := range terms[1:] {
+		t = application(t, rhs)
+	}
+	return t
+}
+
+func (t *Term) is(c Combinator) bool {
+	return t.Kind == KindCombinator && t.Combinator == c
+}
+
+// contains tells whether bound variable at level occurs in t
+func (t *Term) contains(level int) bool {
+	switch t.Kind {
+	case kind_bound:
+		return t.Index == level
+	case KindApplication:
+		return t.Lhs.contains(level) || t.Rhs.contains(level)
+	}
+	return false
+}
+
+// Compile translates term at root into combinators of basis by bracket abstraction:
+//
+//	[x]x     = I
+//	[x]M     = K M, if x does not occur in M
+//	[x](M N) = S [x]M [x]N
+//
+// with the basis rules on top of these. There is no η-rule [x](M x) = M, so combinators
+// reach the same normal form as β-reduction does. Bound variables are kept as levels while
+// abstractions are translated innermost first, so no index is ever shifted
+func Compile(t tree.Tree, root tree.NodeId, basis Basis) *Term {
+	return compile(t, root, basis, false)
+}
+
+// CompileWithEta is Compile with η-rule, that is Turner's S (K p) I = p in his basis.
+// Terms are much smaller, but their normal form is βη one
+func CompileWithEta(t tree.Tree, root tree.NodeId, basis Basis) *Term {
+	return compile(t, root, basis, true)
+}
+
+func compile(t tree.Tree, root tree.NodeId, basis Basis, eta bool) *Term {
+	var aux func(id tree.NodeId, depth int) *Term
+	aux = func(id tree.NodeId, depth int) *Term {
+		node := t.Node(id)
+		switch node.Tag {
+		case tree.NodeIndexVariable:
+			index := ast.ToIndexVariableNode(t, node).Index()
+			if index < depth {
+				return &Term{Kind: kind_bound, Index: depth - 1 - index, Token: node.Token}
+			}
+			return &Term{Kind: KindFree, Index: index - depth, Token: node.Token}
+		case tree.NodePureAbstraction:
+			return abstract(aux(ast.ToPureAbstractionNode(t, node).Body(), depth+1), depth, basis, eta)
+		case tree.NodeApplication:
+			lhs, rhs := ast.ToApplicationNode(t, node).Children()
+			return application(aux(lhs, depth), aux(rhs, depth))
+		default:
+			panic("unreachable")
+		}
+	}
+	return aux(root, 0)
+}
+
+// abstract returns [x]t for x bound at level
+func abstract(t *Term, level int, basis Basis, eta bool) *Term {
+	if !t.contains(level) {
+		return application(combinator(K), t)
+	}
+	if t.Kind == kind_bound {
+		return combinator(I)
+	}
+
+	m, n := t.Lhs, t.Rhs
+	switch basis {
+	case BasisSKI:
+		if eta && !m.contains(level) && n.Kind == kind_bound {
+			return m
+		}
+		return applications(combinator(S), abstract(m, level, basis, eta), abstract(n, level, basis, eta))
+	case BasisBCKW:
+		switch {
+		case eta && !m.contains(level) && n.Kind == kind_bound:
+			return m
+		case !m.contains(level):
+			return applications(combinator(B), m, abstract(n, level, basis, eta))
+		case !n.contains(level):
+			return applications(combinator(C), abstract(m, level, basis, eta), n)
+		}
+		s := applications(combinator(B),
+			application(combinator(B), combinator(W)),
+			applications(combinator(B), combinator(B), combinator(C)))
+		return applications(s, abstract(m, level, basis, eta), abstract(n, level, basis, eta))
+	case BasisTurner:
+		return optimize(abstract(m, level, basis, eta), abstract(n, level, basis, eta), eta)
+	default:
+		panic("unreachable")
+	}
+}
+
+// optimize returns S f g rewritten by Turner's rules, η-rule S (K p) I = p is applied if eta is set
+func optimize(f, g *Term, eta bool) *Term {
+	// argument of combinator c applied to exactly n arguments
+	args := func(t *Term, c Combinator, n int) []*Term {
+		args := make([]*Term, n)
+		for i := n - 1; i >= 0; i-- {
+			if t.Kind != KindApplication {
+				return nil
+			}
+			args[i] = t.Rhs
+			t = t.Lhs
+		}
+		if !t.is(c) {
+			return nil
+		}
+		return args
+	}
+
+	kf, kg := args(f, K, 1), args(g, K, 1)
+	bf, bg := args(f, B, 2), args(g, B, 2)
+	switch {
+	case kf != nil && kg != nil:
+		return application(combinator(K), application(kf[0], kg[0]))
+	case eta && kf != nil && g.is(I):
+		return kf[0]
+	case kf != nil && bg != nil:
+		return applications(combinator(BStar), kf[0], bg[0], bg[1])
+	case kf != nil:
+		return applications(combinator(B), kf[0], g)
+	case bf != nil && kg != nil:
+		return applications(combinator(CPrime), bf[0], bf[1], kg[0])
+	case kg != nil:
+		return applications(combinator(C), f, kg[0])
+	case bf != nil:
+		return applications(combinator(SPrime), bf[0], bf[1], g)
+	}
+	return applications(combinator(S), f, g)
+}
+
+// Size is the number of combinators and variables in t, shared subterms are counted every time
+func Size(t *Term) int {
+	if t.Kind == KindApplication {
+		return Size(t.Lhs) + Size(t.Rhs)
+	}
+	return 1
+}
+
+// Print writes t with applications associated to the left, like S (K x) I.
+// Free variable is printed by its name if it has a token, otherwise by index
+func Print(src source.SourceCode, t *Term) string {
+	str := strings.Builder{}
+	var aux func(t *Term, argument bool)
+	aux = func(t *Term, argument bool) {
+		switch t.Kind {
+		case KindCombinator:
+			str.WriteString(t.Combinator.String())
+		case KindFree, kind_bound:
+			if t.Token >= 0 && int(t.Token) < src.TokenCount() &&
+				src.Token(t.Token).Tag == source.TokenIdentifier {
+				str.WriteString(src.Lexeme(t.Token))
+			} else {
+				str.WriteString(strconv.Itoa(t.Index))
+			}
+		case KindApplication:
+			if argument {
+				str.WriteByte('(')
+			}
+			aux(t.Lhs, false)
+			str.WriteByte(' ')
+			aux(t.Rhs, true)
+			if argument {
+				str.WriteByte(')')
+			}
+		}
+	}
+	aux(t, false)
+	return str.String()
+}
+
+func bound(level int) *Term {
+	return &Term{Kind: kind_bound, Index: level, Token: source.TokenInvalid}
+}
+
+// right hand sides of combinator definitions, argument i is bound at level i
+var combinator_bodies = [...]*Term{
+	S:      applications(bound(0), bound(2), application(bound(1), bound(2))),
+	K:      bound(0),
+	I:      bound(0),
+	B:      application(bound(0), application(bound(1), bound(2))),
+	C:      applications(bound(0), bound(2), bound(1)),
+	W:      applications(bound(0), bound(1), bound(1)),
+	SPrime: applications(bound(0), application(bound(1), bound(3)), application(bound(2), bound(3))),
+	BStar:  application(bound(0), application(bound(1), application(bound(2), bound(3)))),
+	CPrime: applications(bound(0), application(bound(1), bound(3)), bound(2)),
+}
+
+// ToLambda converts t back to lambda term, every combinator is replaced by its definition.
+// Result is not reduced, combinators of it are left as redexes
+func ToLambda(t *Term) tree.Tree {
+	nodes := make([]tree.Node, 0)
+	add_node := func(node tree.Node) tree.NodeId {
+		nodes = append(nodes, node)
+		return tree.NodeId(len(nodes) - 1)
+	}
+
+	var aux func(t *Term, depth int) tree.NodeId
+	aux = func(t *Term, depth int) tree.NodeId {
+		switch t.Kind {
+		case KindCombinator:
+			arity := t.Combinator.Arity()
+			id := aux(combinator_bodies[t.Combinator], arity)
+			for i := 0; i < arity; i++ {
+				id = add_node(tree.Node{
+					Tag:   tree.NodePureAbstraction,
+					Token: source.TokenInvalid,
+					Lhs:   id,
+					Rhs:   tree.NodeNull})
+			}
+			return id
+		case KindFree, kind_bound:
+			index := t.Index + depth
+			if t.Kind == kind_bound {
+				index = depth - 1 - t.Index
+			}
+			return add_node(tree.Node{
+				Tag:   tree.NodeIndexVariable,
+				Token: t.Token,
+				Lhs:   tree.NodeId(index),
+				Rhs:   tree.NodeNull})
+		case KindApplication:
+			lhs := aux(t.Lhs, depth)
+			rhs := aux(t.Rhs, depth)
+			return add_node(tree.Node{
+				Tag:   tree.NodeApplication,
+				Token: t.Token,
+				Lhs:   lhs,
+				Rhs:   rhs})
+		default:
+			panic("unreachable")
+		}
+	}
+	root := aux(t, 0)
+	return tree.NewTree(root, nodes)
+}
@@ -0,0 +1,79 @@
+package ski
+
+import (
+	"context"
+	"lambda/ast/tree"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+)
+
+func TestCompile(test *testing.T) {
+	cases := [...]struct {
+		text              string
+		ski, bckw, turner string
+	}{
+		{`λx.x`, `I`, `I`, `I`},
+		{`λx y.x`, `K`, `K`, `K`},
+		{`λx.(x y)`, `S I (K y)`, `C I y`, `C I y`},
+		{`λf x.(f (f x))`,
+			`S (S (K S) K) I`,
+			`B (B W) (B B C) B I`,
+			`S B I`},
+		{`λx y z.(x z (y z))`, ``, `B (B W) (B B C)`, `S`},
+		{`λx y z.(x (y z))`, ``, `B`, `B`},
+		{`λx y z.(x z y)`, ``, `C`, `C`},
+		{`λc f g x.(c (f x) (g x))`, ``, ``, `S'`},
+		{`λc f g x.(c (f (g x)))`, ``, ``, `B*`},
+		{`λc f g x.(c (f x) g)`, ``, ``, `C'`},
+	}
+	for _, c := range cases {
+		src, t := evaltest.DeBruijn(test, c.text)
+		for basis, expected := range [...]string{BasisSKI: c.ski, BasisBCKW: c.bckw, BasisTurner: c.turner} {
+			if expected == "" {
+				continue
+			}
+			if got := Print(src, CompileWithEta(t, t.RootId(), Basis(basis))); got != expected {
+				test.Errorf("%s in %s basis: expected %s got %s", c.text, Basis(basis), expected, got)
+			}
+		}
+	}
+}
+
+func TestCompileWithoutEta(test *testing.T) {
+	cases := [...]struct {
+		text, expected string
+	}{
+		{`λx.x`, `I`},
+		{`λx y.x`, `B K I`},
+		{`λx.(x y)`, `C I y`},
+		{`λx y.(x y)`, `C' B I I`},
+	}
+	for _, c := range cases {
+		src, t := evaltest.DeBruijn(test, c.text)
+		if got := Print(src, Compile(t, t.RootId(), BasisTurner)); got != c.expected {
+			test.Errorf("%s: expected %s got %s", c.text, c.expected, got)
+		}
+	}
+}
+
+func TestToLambda(test *testing.T) {
+	texts := []string{
+		`x`,
+		`λx.x`,
+		`λx y.(y x)`,
+		`λx y.(x (λz.z) y q)`,
+		`((λx.λy.(y x)) (λz.z))`,
+		`λf x.(f (f (f x)))`,
+		`λc f g x.(c (f x) (g x) (c (f (g x))) (c (f x) g))`,
+	}
+	for basis := BasisSKI; basis <= BasisTurner; basis++ {
+		basis := basis
+		test.Run(basis.String(), func(test *testing.T) {
+			evaltest.Compare(test, func(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options eval.EvalOptions) (tree.Tree, error) {
+				lambda := ToLambda(Compile(in_tree, root, basis))
+				return eval.Eval(ctx, lambda, lambda.RootId(), options)
+			}, eval.EvalOptions{}, texts...)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package vm compiles de bruijn tree into bytecode of lazy Krivine machine and runs it
+package vm
+
+import (
+	"fmt"
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/syntax/source"
+	"strings"
+)
+
+type Opcode uint8
+
+const (
+	// ACCESS n enters value of n-th variable of environment
+	OpAccess Opcode = iota
+	// GRAB moves argument from stack to environment, or stops if there is none
+	OpGrab
+	// PUSH addr pushes suspended code at addr with current environment
+	OpPush
+	// FREE n is n-th free variable of the program, it is applied to arguments on stack
+	OpFree
+)
+
+var opcode_names = [...]string{
+	OpAccess: "ACCESS",
+	OpGrab:   "GRAB",
+	OpPush:   "PUSH",
+	OpFree:   "FREE",
+}
+
+func (op Opcode) String() string {
+	return opcode_names[op]
+}
+
+type Instruction struct {
+	Op  Opcode
+	Arg int32
+}
+
+// Program is code of a term. Every code block ends with ACCESS or FREE, that jump
+// elsewhere, so there are no returns. Tokens are the ones of nodes that instructions
+// come from, they name abstractions and free variables on readback
+type Program struct {
+	Code   []Instruction
+	Tokens []source.TokenId
+	Entry  int32
+}
+
+// Compile translates term at root of de bruijn tree:
+//
+//	n     => ACCESS n (or FREE n-depth for free variable)
+//	λt    => GRAB; t
+//	(t u) => PUSH u; t
+//
+// Code of argument is placed after the code of term that pushes it
+func Compile(t tree.Tree, root tree.NodeId) Program {
+	p := Program{}
+	emit := func(op Opcode, arg int32, token source.TokenId) int {
+		p.Code = append(p.Code, Instruction{Op: op, Arg: arg})
+		p.Tokens = append(p.Tokens, token)
+		return len(p.Code) - 1
+	}
+
+	type block struct {
+		term  tree.NodeId
+		depth int
+		// PUSH that refers to the block, -1 for entry
+		push int
+	}
+	blocks := []block{{term: root, depth: 0, push: -1}}
+	for len(blocks) > 0 {
+		b := blocks[len(blocks)-1]
+		blocks = blocks[:len(blocks)-1]
+		start := int32(len(p.Code))
+		if b.push >= 0 {
+			p.Code[b.push].Arg = start
+		} else {
+			p.Entry = start
+		}
+
+		for id, depth := b.term, b.depth; ; {
+			node := t.Node(id)
+			if node.Tag == tree.NodeIndexVariable {
+				index := ast.ToIndexVariableNode(t, node).Index()
+				if index < depth {
+					emit(OpAccess, int32(index), node.Token)
+				} else {
+					emit(OpFree, int32(index-depth), node.Token)
+				}
+				break
+			}
+			switch node.Tag {
+			case tree.NodePureAbstraction:
+				emit(OpGrab, 0, node.Token)
+				id = ast.ToPureAbstractionNode(t, node).Body()
+				depth++
+			case tree.NodeApplication:
+				app := ast.ToApplicationNode(t, node)
+				push := emit(OpPush, -1, node.Token)
+				blocks = append(blocks, block{term: app.Rhs(), depth: depth, push: push})
+				id = app.Lhs()
+			default:
+				panic("unreachable")
+			}
+		}
+	}
+	return p
+}
+
+// Disassemble lists instructions one per line with their addresses, entry is marked with >
+func Disassemble(p Program) string {
+	str := strings.Builder{}
+	for i, instruction := range p.Code {
+		mark := ' '
+		if int32(i) == p.Entry {
+			mark = '>'
+		}
+		fmt.Fprintf(&str, "%c%04d  %s", mark, i, instruction.Op)
+		if instruction.Op != OpGrab {
+			fmt.Fprintf(&str, " %d", instruction.Arg)
+		}
+		str.WriteByte('\n')
+	}
+	return str.String()
+}
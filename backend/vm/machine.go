@@ -0,0 +1,277 @@
+package vm
+
+import (
+	"context"
+	"lambda/ast/tree"
+	"lambda/eval"
+	"lambda/syntax/source"
+)
+
+type cell_tag uint8
+
+const (
+	// a is thunk, b is next cell of environment
+	cell_env cell_tag = iota
+	// suspended code: a is address, b is environment
+	cell_thunk
+	// evaluated abstraction: a is address of GRAB, b is environment
+	cell_closure
+	// head variable applied to arguments: a is head, b is list of arguments (last one first),
+	// c is token of head. Head is free index if non-negative, otherwise ^level of abstraction
+	cell_neutral
+	// a is thunk of argument, b is next cell of list
+	cell_args
+	// cell is moved by garbage collector to a
+	cell_forward
+)
+
+// nil reference
+const null int32 = -1
+
+type cell struct {
+	tag     cell_tag
+	a, b, c int32
+}
+
+// heap is collected when it grows over this number of cells at first
+const initial_heap = 1 << 16
+
+type machine struct {
+	program Program
+	heap    []cell
+	// heap size that triggers collection
+	threshold int
+	// arguments are thunks, update markers are ^thunk
+	stack []int32
+	// cells that readback holds while machine runs
+	roots  []int32
+	env    int32
+	budget *eval.Budget
+}
+
+func (m *machine) alloc(tag cell_tag, a, b, c int32) int32 {
+	m.heap = append(m.heap, cell{tag: tag, a: a, b: b, c: c})
+	return int32(len(m.heap) - 1)
+}
+
+// Run evaluates program to normal form. Thunks are updated with their values, so each
+// argument is evaluated at most once, and abstractions are read back by applying them
+// to fresh variables. Limits of options are checked, MaxNodes bounds live cells of the heap,
+// Partial term of LimitError is empty, as VM keeps no tree
+func Run(ctx context.Context, p Program, options eval.EvalOptions) (result tree.Tree, err error) {
+	b := eval.NewBudget(ctx, options)
+	err = b.Run(func() {
+		result = evaluate(b, p)
+	})
+	if err == nil && options.Tracer != nil {
+		options.Tracer.OnDone(b.Reductions(), result)
+	}
+	return result, err
+}
+
+func evaluate(b *eval.Budget, p Program) tree.Tree {
+	m := machine{
+		program:   p,
+		heap:      make([]cell, 0, initial_heap),
+		threshold: initial_heap,
+		env:       null,
+		budget:    b,
+	}
+	nodes := make([]tree.Node, 0)
+	value := m.run(p.Entry, null, 0)
+	root := m.readback(&nodes, value, 0)
+	return tree.NewTree(root, nodes)
+}
+
+// Eval compiles term and runs it, it is a backend of eval.EvalWhole
+func Eval(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options eval.EvalOptions) (tree.Tree, error) {
+	return eval.EvalWhole(ctx, options, func(b *eval.Budget) tree.Tree {
+		return evaluate(b, Compile(in_tree, root))
+	})
+}
+
+// run executes code at pc in environment env until there are no arguments above base
+// of the stack, then returns weak head normal form that is reached
+func (m *machine) run(pc, env int32, base int) int32 {
+	m.env = env
+	code := m.program.Code
+	for {
+		instruction := code[pc]
+		switch instruction.Op {
+		case OpPush:
+			thunk := m.alloc(cell_thunk, instruction.Arg, m.env, 0)
+			m.stack = append(m.stack, thunk)
+			pc++
+		case OpGrab:
+			// update markers are above arguments of the abstraction
+			for len(m.stack) > base && m.stack[len(m.stack)-1] < 0 {
+				marker := ^m.stack[len(m.stack)-1]
+				m.stack = m.stack[:len(m.stack)-1]
+				m.heap[marker] = cell{tag: cell_closure, a: pc, b: m.env}
+			}
+			if len(m.stack) == base {
+				return m.alloc(cell_closure, pc, m.env, 0)
+			}
+			m.reduce()
+			argument := m.stack[len(m.stack)-1]
+			m.stack = m.stack[:len(m.stack)-1]
+			m.env = m.alloc(cell_env, argument, m.env, 0)
+			pc++
+		case OpAccess:
+			e := m.env
+			for i := instruction.Arg; i > 0; i-- {
+				e = m.heap[e].b
+			}
+			thunk := m.heap[e].a
+			switch c := m.heap[thunk]; c.tag {
+			case cell_closure:
+				pc, m.env = c.a, c.b
+			case cell_thunk:
+				m.stack = append(m.stack, ^thunk)
+				pc, m.env = c.a, c.b
+			case cell_neutral:
+				return m.apply_neutral(thunk, base)
+			default:
+				panic("unreachable")
+			}
+		case OpFree:
+			neutral := m.alloc(cell_neutral, instruction.Arg, null, int32(m.program.Tokens[pc]))
+			return m.apply_neutral(neutral, base)
+		}
+	}
+}
+
+// apply_neutral applies neutral term to arguments on the stack, updating thunks on the way
+func (m *machine) apply_neutral(neutral int32, base int) int32 {
+	for len(m.stack) > base {
+		top := m.stack[len(m.stack)-1]
+		m.stack = m.stack[:len(m.stack)-1]
+		if top < 0 {
+			m.heap[^top] = m.heap[neutral]
+			continue
+		}
+		n := m.heap[neutral]
+		args := m.alloc(cell_args, top, n.b, 0)
+		neutral = m.alloc(cell_neutral, n.a, args, n.c)
+	}
+	return neutral
+}
+
+// reduce counts β-reduction, checks limits and collects garbage when heap is full
+func (m *machine) reduce() {
+	m.budget.Reduce()
+	if len(m.heap) >= m.threshold {
+		m.collect()
+		m.budget.Nodes(len(m.heap))
+		if len(m.heap) > m.threshold/2 {
+			m.threshold *= 2
+		}
+	}
+}
+
+// collect copies cells reachable from environment, stack and roots to new heap (Cheney)
+func (m *machine) collect() {
+	to := make([]cell, 0, len(m.heap)/2+1)
+	forward := func(i int32) int32 {
+		if i < 0 {
+			return i
+		}
+		if c := m.heap[i]; c.tag == cell_forward {
+			return c.a
+		}
+		to = append(to, m.heap[i])
+		moved := int32(len(to) - 1)
+		m.heap[i] = cell{tag: cell_forward, a: moved}
+		return moved
+	}
+
+	m.env = forward(m.env)
+	for i, s := range m.stack {
+		if s < 0 {
+			m.stack[i] = ^forward(^s)
+		} else {
+			m.stack[i] = forward(s)
+		}
+	}
+	for i, r := range m.roots {
+		m.roots[i] = forward(r)
+	}
+	for scan := 0; scan < len(to); scan++ {
+		switch to[scan].tag {
+		case cell_env, cell_args:
+			a := forward(to[scan].a)
+			b := forward(to[scan].b)
+			to[scan].a, to[scan].b = a, b
+		case cell_thunk, cell_closure, cell_neutral:
+			b := forward(to[scan].b)
+			to[scan].b = b
+		default:
+			panic("unreachable")
+		}
+	}
+	m.heap = to
+}
+
+// force evaluates thunk to weak head normal form
+func (m *machine) force(thunk int32) int32 {
+	if m.heap[thunk].tag != cell_thunk {
+		return thunk
+	}
+	m.roots = append(m.roots, thunk)
+	c := m.heap[thunk]
+	base := len(m.stack)
+	m.stack = append(m.stack, ^thunk)
+	m.run(c.a, c.b, base)
+	thunk = m.roots[len(m.roots)-1]
+	m.roots = m.roots[:len(m.roots)-1]
+	return thunk
+}
+
+// readback quotes weak head normal form to normal form, depth is the number of abstractions above it
+func (m *machine) readback(nodes *[]tree.Node, value int32, depth int) tree.NodeId {
+	add_node := func(node tree.Node) tree.NodeId {
+		*nodes = append(*nodes, node)
+		return tree.NodeId(len(*nodes) - 1)
+	}
+
+	c := m.heap[value]
+	if c.tag == cell_closure {
+		token := m.program.Tokens[c.a]
+		// body is entered right after GRAB, that would take fresh variable from the stack
+		variable := m.alloc(cell_neutral, ^int32(depth), null, int32(token))
+		env := m.alloc(cell_env, variable, c.b, 0)
+		body := m.run(c.a+1, env, len(m.stack))
+		return add_node(tree.Node{
+			Tag:   tree.NodePureAbstraction,
+			Token: token,
+			Lhs:   m.readback(nodes, body, depth+1),
+			Rhs:   tree.NodeNull})
+	}
+
+	index := int(c.a) + depth
+	if c.a < 0 {
+		index = depth - 1 - int(^c.a)
+	}
+	head := add_node(tree.Node{
+		Tag:   tree.NodeIndexVariable,
+		Token: source.TokenId(c.c),
+		Lhs:   tree.NodeId(index),
+		Rhs:   tree.NodeNull})
+
+	// arguments stay reachable while they are read back one by one
+	base := len(m.roots)
+	for args := c.b; args != null; args = m.heap[args].b {
+		m.roots = append(m.roots, m.heap[args].a)
+	}
+	count := len(m.roots) - base
+	for i := count - 1; i >= 0; i-- {
+		argument := m.readback(nodes, m.force(m.roots[base+i]), depth)
+		head = add_node(tree.Node{
+			Tag:   tree.NodeApplication,
+			Token: source.TokenInvalid,
+			Lhs:   head,
+			Rhs:   argument})
+	}
+	m.roots = m.roots[:base]
+	return head
+}
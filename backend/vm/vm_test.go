@@ -0,0 +1,80 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"lambda/ast/ast"
+	"lambda/ast/sexpr"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+)
+
+func TestCompile(test *testing.T) {
+	_, t := evaltest.DeBruijn(test, `λx.(x (λy.y) z)`)
+	expected := `>0000  GRAB
+ 0001  PUSH 6
+ 0002  PUSH 4
+ 0003  ACCESS 0
+ 0004  GRAB
+ 0005  ACCESS 0
+ 0006  FREE 0
+`
+	if got := Disassemble(Compile(t, t.RootId())); got != expected {
+		test.Errorf("Expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestEval(test *testing.T) {
+	texts := []string{
+		`x`,
+		`λx.((λy.y) x)`,
+		`(λx y.x) ((λz.z) a)`,
+		`λx.λy.(x ((λz.(z y)) x))`,
+		`(λx.λy.λz.(z x y)) (a b) (λw.(w c))`,
+		`(λx y.y) ((λx.(x x)) (λx.(x x))) z`,
+		`λf.(f (λx.x) (f a))`,
+		evaltest.Prelude + `(Fact 3)`,
+		evaltest.Prelude + `(λx.(Mult x x)) (Fact 3)`,
+	}
+	evaltest.Compare(test, Eval, eval.EvalOptions{}, texts...)
+}
+
+func TestEvalLimits(test *testing.T) {
+	_, t := evaltest.DeBruijn(test, `(λx.(x x)) (λx.(x x))`)
+	_, err := Eval(context.Background(), t, t.RootId(), eval.EvalOptions{MaxReductions: 100000})
+	var limit *eval.LimitError
+	if !errors.As(err, &limit) || limit.Limit != eval.LimitReductions || limit.Partial.Count() != 0 {
+		test.Errorf("Expected reduction limit, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Eval(ctx, t, t.RootId(), eval.EvalOptions{}); !errors.Is(err, context.Canceled) {
+		test.Errorf("Expected cancellation, got %v", err)
+	}
+
+	// every reduction adds to the stack
+	_, t = evaltest.DeBruijn(test, `(λx.(x x x)) (λx.(x x x))`)
+	if _, err := Eval(context.Background(), t, t.RootId(), eval.EvalOptions{MaxNodes: 1000}); !errors.As(err, &limit) || limit.Limit != eval.LimitNodes {
+		test.Errorf("Expected node limit, got %v", err)
+	}
+}
+
+func TestEvalLarge(test *testing.T) {
+	// heap is collected many times on the way
+	source_code, t := evaltest.DeBruijn(test, evaltest.Prelude+`(Fact 6)`)
+	expected, err := eval.CallByNeed(context.Background(), t, t.RootId(), eval.EvalOptions{})
+	if err != nil {
+		test.Fatal(err)
+	}
+	got, err := Eval(context.Background(), t, t.RootId(), eval.EvalOptions{})
+	if err != nil {
+		test.Fatal(err)
+	}
+	lhs := ast.Print(source_code, got, got.RootId())
+	rhs := ast.Print(source_code, expected, expected.RootId())
+	if sexpr.Minified(lhs) != sexpr.Minified(rhs) {
+		test.Error("Results of call by need and vm differ")
+	}
+}
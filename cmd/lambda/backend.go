@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"lambda/ast/tree"
+	"lambda/backend/inet"
+	"lambda/backend/ski"
+	"lambda/backend/vm"
+	"lambda/eval"
+	"strings"
+)
+
+type backend struct {
+	name, description string
+	// strategies that backend can follow, any if empty
+	strategies []string
+	// whether Tracer gets every reduction, so -trace can print it
+	traces bool
+	eval   func(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options eval.EvalOptions) (tree.Tree, error)
+}
+
+var backends = [...]backend{
+	{"tree", "rewriting of de bruijn tree", nil, true, eval.Eval},
+	{"krivine", "Krivine abstract machine", []string{"whnf"}, false, eval.Krivine},
+	{"need", "call by need with shared arguments", []string{"normal"}, false, eval.CallByNeed},
+	{"parallel", "parallel outermost reduction on all processors", []string{"normal"}, true, eval.Parallel},
+	{"vm", "bytecode of lazy Krivine machine", []string{"normal"}, false, vm.Eval},
+	{"nbe", "normalization by evaluation", []string{"normal"}, false, eval.Normalize},
+	{"ski", "graph reduction of Turner's combinators", []string{"normal"}, false, ski.Eval},
+	{"inet", "optimal reduction of interaction nets (experimental)", []string{"normal"}, false, inet.Eval},
+	{"race", "normal order, call by need and applicative order at once, the first to finish wins", []string{"normal"}, false, race},
+}
+
+func race(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options eval.EvalOptions) (tree.Tree, error) {
+	result, _, err := eval.Race(ctx, in_tree, root, options)
+	return result, err
+}
+
+func backend_by_name(name string) (backend, bool) {
+	for _, b := range backends {
+		if b.name == name {
+			return b, true
+		}
+	}
+	return backend{}, false
+}
+
+// tracing_backend_names lists backends that -trace works with
+func tracing_backend_names() string {
+	names := make([]string, 0, len(backends))
+	for _, b := range backends {
+		if b.traces {
+			names = append(names, b.name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func backend_names() string {
+	names := make([]string, 0, len(backends))
+	for _, b := range backends {
+		names = append(names, b.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// default_strategy is the strategy backend follows when none is asked for
+func (b backend) default_strategy() string {
+	if len(b.strategies) == 0 {
+		return "normal"
+	}
+	return b.strategies[0]
+}
+
+func (b backend) supports(strategy string) bool {
+	if len(b.strategies) == 0 {
+		return true
+	}
+	for _, s := range b.strategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
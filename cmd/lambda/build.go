@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"lambda/backend/golang"
+	"lambda/middle/numeral"
+	"lambda/util"
+	"os"
+)
+
+func run_build(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lambda build", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: lambda build [flags] [file]")
+		fmt.Fprintln(stderr, "Translates program to Go source, go build on it gives binary that prints the result")
+		flags.PrintDefaults()
+	}
+	entry := flags.String("entry", "", "evaluate definition with this name instead of main term")
+	strict := flags.Bool("strict", false, "use strict grammar (unary abstractions, parenthesized applications)")
+	numerals := flags.String("numerals", "church", "encoding of numeric literals: church, scott or binary")
+	decode := flags.Bool("decode", false, "print numeral result as integer")
+	output := flags.String("o", "", "write Go source to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+	encoding, ok := numeral.ParseEncoding(*numerals)
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown numeral encoding %s\n", *numerals)
+		return 2
+	}
+
+	filename := "stdin"
+	var text []byte
+	var err error
+	if flags.NArg() == 0 || flags.Arg(0) == "-" {
+		text, err = io.ReadAll(stdin)
+	} else {
+		filename = flags.Arg(0)
+		text, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	logger := util.NewLogger()
+	_, result, ok := compile(&logger, parser_mode(*strict), encoding, filename, string(text), *entry)
+	if !ok {
+		report_errors(stderr, &logger)
+		return 1
+	}
+	code, err := golang.Generate(result.Tree, result.Tree.RootId(), golang.Options{
+		DecodeNumerals: *decode,
+		Encoding:       encoding,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if *output == "" {
+		_, err = stdout.Write(code)
+	} else {
+		err = os.WriteFile(*output, code, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/eval"
+	debruijn "lambda/middle/de-bruijn"
+	"lambda/middle/numeral"
+	"lambda/syntax/loader"
+	"lambda/syntax/source"
+	"lambda/util"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const debug_help = `Current term is shown with the next redex in [brackets].
+
+Commands:
+    step, s             contract the next redex
+    next, n [N]         contract N redexes (1 by default), stopping at breakpoints
+    continue, c         contract redexes until normal form or breakpoint
+    back [N]            undo N reductions (1 by default)
+    goto <step>         go to the term after given number of reductions, back or forward
+    break on <name>     stop before let or def with this name is bound and whenever it is applied
+    break off <name>    remove breakpoint
+    break               list breakpoints
+    print, p [form]     show current term, form (index or named) is kept for later output
+    help, h             show this message
+    quit, q             leave the debugger
+`
+
+const (
+	debug_prompt = "(debug) "
+	// continue gives control back after this many reductions without breakpoint
+	debug_continue_limit = 1000000
+	// snapshots of the term kept for going back
+	debug_checkpoints = 256
+	highlight_open    = "["
+	highlight_close   = "]"
+)
+
+type debugger struct {
+	source_code    source.SourceCode
+	variable_names map[int]string
+	evaluator      *eval.Evaluator
+	// tokens of binders that belong to every let or def name
+	bindings    map[string][]source.TokenId
+	breakpoints map[string]bool
+	// step where breakpoint was reported, run resumes from it instead of stopping again
+	stopped int
+	named   bool
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+func run_debug(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lambda debug", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: lambda debug [flags] file")
+		fmt.Fprintln(stderr, "Commands are read from stdin, see help command")
+		flags.PrintDefaults()
+	}
+	entry := flags.String("entry", "", "evaluate definition with this name instead of main term")
+	strict := flags.Bool("strict", false, "use strict grammar (unary abstractions, parenthesized applications)")
+	strategy_name := flags.String("strategy", "normal", "evaluation strategy: "+strings.Join(eval.StrategyNames(), ", "))
+	named := flags.Bool("named", false, "print terms with names instead of de bruijn indices")
+	numerals := flags.String("numerals", "church", "encoding of numeric literals: church, scott or binary")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	encoding, ok := numeral.ParseEncoding(*numerals)
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown numeral encoding %s\n", *numerals)
+		return 2
+	}
+	strategy, ok := eval.StrategyByName(*strategy_name)
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown strategy %s\n", *strategy_name)
+		return 2
+	}
+
+	filename := flags.Arg(0)
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	logger := util.NewLogger()
+	source_code, named_tree, ok := compile_named(&logger, parser_mode(*strict), filename, string(text), *entry)
+	if !ok {
+		report_errors(stderr, &logger)
+		return 1
+	}
+	result := lower(source_code, named_tree, encoding)
+
+	d := debugger{
+		source_code:    source_code,
+		variable_names: result.VariableNames,
+		evaluator:      eval.NewEvaluatorWithHistory(result.Tree, result.Tree.RootId(), eval.EvalOptions{Strategy: strategy}, debug_checkpoints),
+		bindings:       bindings(source_code, named_tree),
+		breakpoints:    make(map[string]bool),
+		stopped:        -1,
+		named:          *named,
+		stdout:         stdout,
+		stderr:         stderr,
+	}
+	d.show()
+	scanner := bufio.NewScanner(stdin)
+	fmt.Fprint(stdout, debug_prompt)
+	for scanner.Scan() {
+		if quit := d.command(scanner.Text()); quit {
+			return 0
+		}
+		fmt.Fprint(stdout, debug_prompt)
+	}
+	return 0
+}
+
+// bindings collects names bound by let and def together with tokens of abstractions
+// that make redex when such binding is applied: the one formed by let itself
+// and the leading abstraction of the bound value, if value is an abstraction
+func bindings(source_code source.SourceCode, named_tree tree.Tree) map[string][]source.TokenId {
+	result := make(map[string][]source.TokenId)
+	on_enter := func(t tree.Tree, id tree.NodeId) {
+		node := t.Node(id)
+		if node.Tag != tree.NodeLetBinding {
+			return
+		}
+		binding := ast.ToLetBindingNode(t, node)
+		bound := t.Node(binding.Bound()).Token
+		name := source_code.Lexeme(bound)
+		if i := strings.LastIndex(name, loader.QualifiedSeparator); i >= 0 {
+			name = name[i+len(loader.QualifiedSeparator):]
+		}
+		result[name] = append(result[name], bound)
+		if value := t.Node(binding.Value()); value.Tag == tree.NodeAbstraction {
+			abstraction := ast.ToAbstractionNode(t, value)
+			result[name] = append(result[name], t.Node(abstraction.Bound()).Token)
+		}
+	}
+	ast.TraversePreorder(named_tree, named_tree.RootId(), on_enter, func(tree.Tree, tree.NodeId) {})
+	return result
+}
+
+func (d *debugger) command(line string) (quit bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	switch fields[0] {
+	case "quit", "q":
+		return true
+	case "help", "h":
+		fmt.Fprint(d.stdout, debug_help)
+	case "step", "s":
+		d.run(1)
+	case "next", "n":
+		if n, ok := d.count(fields); ok {
+			d.run(n)
+		}
+	case "continue", "c":
+		d.run(debug_continue_limit)
+	case "back":
+		if n, ok := d.count(fields); ok {
+			step := d.evaluator.Reductions() - n
+			if step < 0 {
+				step = 0
+			}
+			d.evaluator.Goto(step)
+			d.stopped = -1
+			d.show()
+		}
+	case "goto":
+		if len(fields) != 2 {
+			fmt.Fprintln(d.stderr, "Usage: goto <step>")
+			return
+		}
+		step, err := strconv.Atoi(fields[1])
+		if err != nil || step < 0 {
+			fmt.Fprintf(d.stderr, "Expected step number, got %s\n", fields[1])
+			return
+		}
+		if !d.evaluator.Goto(step) {
+			fmt.Fprintf(d.stderr, "Normal form is reached before step %d\n", step)
+		}
+		d.stopped = -1
+		d.show()
+	case "break", "b":
+		d.breakpoint(fields[1:])
+	case "print", "p":
+		if len(fields) > 1 {
+			if fields[1] != "index" && fields[1] != "named" {
+				fmt.Fprintf(d.stderr, "Unknown form %s, available are: index, named\n", fields[1])
+				return
+			}
+			d.named = fields[1] == "named"
+		}
+		d.show()
+	default:
+		fmt.Fprintf(d.stderr, "Unknown command %s, see help\n", fields[0])
+	}
+	return
+}
+
+// count reads optional number of steps that follows command
+func (d *debugger) count(fields []string) (int, bool) {
+	if len(fields) == 1 {
+		return 1, true
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil || n < 1 {
+		fmt.Fprintf(d.stderr, "Expected positive number of steps, got %s\n", fields[1])
+		return 0, false
+	}
+	return n, true
+}
+
+func (d *debugger) breakpoint(args []string) {
+	if len(args) == 0 {
+		names := make([]string, 0, len(d.breakpoints))
+		for name := range d.breakpoints {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintln(d.stdout, name)
+		}
+		return
+	}
+	if len(args) != 2 || (args[0] != "on" && args[0] != "off") {
+		fmt.Fprintln(d.stderr, "Usage: break [on|off <name>]")
+		return
+	}
+	name := args[1]
+	if _, ok := d.bindings[name]; !ok {
+		fmt.Fprintf(d.stderr, "There is no let or def named %s\n", name)
+		return
+	}
+	if args[0] == "on" {
+		d.breakpoints[name] = true
+		// new breakpoint stops at the current redex too
+		d.stopped = -1
+	} else {
+		delete(d.breakpoints, name)
+	}
+}
+
+// hit returns name of breakpoint that the next redex belongs to
+func (d *debugger) hit() (string, bool) {
+	// redex first, tree is valid only after the redex is found
+	redex := d.evaluator.Redex()
+	t := d.evaluator.Tree()
+	if redex == tree.NodeNull {
+		return "", false
+	}
+	function := t.Node(ast.ToApplicationNode(t, t.Node(redex)).Lhs())
+	for name := range d.breakpoints {
+		for _, token := range d.bindings[name] {
+			if function.Token == token {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// run contracts up to n redexes, it stops early in normal form or before redex of breakpoint,
+// including the current one unless run resumes from it
+func (d *debugger) run(n int) {
+	for i := 0; ; i++ {
+		if name, ok := d.hit(); ok && d.evaluator.Reductions() != d.stopped {
+			fmt.Fprintf(d.stdout, "Breakpoint %s\n", name)
+			d.stopped = d.evaluator.Reductions()
+			break
+		}
+		if i == n || d.evaluator.Done() {
+			break
+		}
+		d.evaluator.Step()
+	}
+	d.show()
+}
+
+// show prints current term with the next redex highlighted
+func (d *debugger) show() {
+	redex := d.evaluator.Redex()
+	t := d.evaluator.Tree()
+	var term string
+	if d.named {
+		named_source, named_tree, mapping := debruijn.FromDeBruijnWithMapping(d.source_code, d.variable_names, t)
+		highlight := tree.NodeNull
+		if redex != tree.NodeNull {
+			highlight = mapping[redex]
+		}
+		term = ast.PrintNamedWithHighlight(named_source, named_tree, named_tree.RootId(), highlight, highlight_open, highlight_close)
+	} else {
+		term = strings.TrimSpace(ast.PrintWithHighlight(d.source_code, t, t.RootId(), redex, highlight_open, highlight_close))
+	}
+	fmt.Fprintf(d.stdout, "%d: %s\n", d.evaluator.Reductions(), term)
+	if redex == tree.NodeNull {
+		fmt.Fprintln(d.stdout, "Normal form")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testDebug(test *testing.T, program, commands string, args ...string) string {
+	path := filepath.Join(test.TempDir(), "program.lc")
+	if err := os.WriteFile(path, []byte(program), 0o644); err != nil {
+		test.Fatal(err)
+	}
+	code, stdout, stderr := testRun(append(append([]string{"debug"}, args...), path), commands)
+	if code != 0 || stderr != "" {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	return stdout
+}
+
+func TestDebugStep(test *testing.T) {
+	stdout := testDebug(test, `((λx.x) ((λy.y) z))`, "step\nprint named\nnext 5\n")
+	expected := []string{
+		"0: [((λ 0)((λ 0) 0))]",
+		"1: [((λ 0) 0)]",
+		"1: [((λy.y) z)]",
+		"2: z\nNormal form",
+	}
+	for _, e := range expected {
+		if !strings.Contains(stdout, e) {
+			test.Errorf("Expected %q in output:\n%s", e, stdout)
+		}
+	}
+}
+
+func TestDebugBreakpoint(test *testing.T) {
+	program := `
+def Id = λx.x
+def Twice = λf x.(f (f x))
+(Twice Id z)
+`
+	stdout := testDebug(test, program, "break on Id\ncontinue\ncontinue\ncontinue\nbreak off Id\ncontinue\n", "-named")
+	if strings.Count(stdout, "Breakpoint Id") != 3 {
+		test.Errorf("Expected Id to be bound once and applied twice, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "[((λx.x) ") {
+		test.Errorf("Expected application of Id to be highlighted, got:\n%s", stdout)
+	}
+	if !strings.HasSuffix(stdout, "z\nNormal form\n"+debug_prompt) {
+		test.Errorf("Expected normal form at the end, got:\n%s", stdout)
+	}
+
+	code, _, stderr := testRun([]string{"debug", filepath.Join(test.TempDir(), "missing.lc")}, "")
+	if code == 0 || stderr == "" {
+		test.Error("Expected failure on missing file")
+	}
+}
+
+func TestDebugBreakpointAtCurrentRedex(test *testing.T) {
+	program := `
+def Id = λx.x
+(Id z)
+`
+	// the first redex binds Id, continue stops there and then resumes from it
+	stdout := testDebug(test, program, "break on Id\ncontinue\ncontinue\ncontinue\n", "-named")
+	expected := "Breakpoint Id\n0: [((λId.(Id z)) λx.x)]\n" + debug_prompt +
+		"Breakpoint Id\n1: [((λx.x) z)]\n" + debug_prompt +
+		"2: z\nNormal form\n"
+	if !strings.Contains(stdout, expected) {
+		test.Errorf("Expected breakpoint at the first redex, got:\n%s", stdout)
+	}
+
+	// going back forgets that breakpoint was reported
+	stdout = testDebug(test, program, "break on Id\ncontinue\ncontinue\nback\ncontinue\n", "-named")
+	if strings.Count(stdout, "Breakpoint Id\n0: ") != 2 {
+		test.Errorf("Expected breakpoint after going back, got:\n%s", stdout)
+	}
+}
+
+func TestDebugHighlightAfterGC(test *testing.T) {
+	// garbage of the term is collected every 10 steps
+	program := `
+def Pair = λa b f.f a b
+def Nest = λn.n (λp.Pair p p) (λx.x)
+Nest 8
+`
+	stdout := testDebug(test, program, "goto 10\ngoto 20\n")
+	for _, step := range [...]string{"10: ", "20: "} {
+		i := strings.Index(stdout, debug_prompt+step)
+		if i < 0 {
+			test.Fatalf("Expected step %s in output:\n%s", step, stdout)
+		}
+		line := stdout[i:]
+		line = line[:strings.IndexByte(line, '\n')]
+		if !strings.Contains(line, highlight_open) {
+			test.Errorf("Expected highlighted redex at step %s got %s", step, line)
+		}
+	}
+}
+
+func TestDebugBack(test *testing.T) {
+	stdout := testDebug(test, `((λx.x) ((λy.y) z))`, "continue\nback\nback 5\ngoto 2\n")
+	expected := []string{
+		"2: 0\nNormal form",
+		"1: [((λ 0) 0)]",
+		"0: [((λ 0)((λ 0) 0))]",
+	}
+	for _, e := range expected {
+		if !strings.Contains(stdout, e) {
+			test.Errorf("Expected %q in output:\n%s", e, stdout)
+		}
+	}
+	if strings.Count(stdout, "2: 0\nNormal form") != 2 {
+		test.Errorf("Expected goto to return to normal form, got:\n%s", stdout)
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"lambda/ast/ast"
+	"lambda/ast/module"
+	"lambda/ast/tree"
+	"lambda/backend/ski"
+	"lambda/backend/vm"
+	"lambda/eval"
+	debruijn "lambda/middle/de-bruijn"
+	"lambda/middle/desugar"
+	"lambda/middle/numeral"
+	"lambda/syntax/loader"
+	"lambda/syntax/parser"
+	"lambda/syntax/source"
+	"lambda/util"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/utf8string"
+)
+
+const usage = `Usage:
+    lambda [flags] [file]
+    lambda repl [flags]
+    lambda debug [flags] [file]
+    lambda build [flags] [file]
+
+Evaluates lambda calculus program from file (or stdin if file is omitted or "-")
+and prints the result in de bruijn form (or with names, if -named is given). Program is a sequence of imports, like
+import "bool.lc" or import "num.lc" as N, and "def <name> = <term>" definitions
+followed by main term, that is evaluated unless -entry is given. Numeric literals
+are encoded as -numerals tells, and numeral result is printed as integer if -decode is given.
+Subcommand repl starts interactive session, debug steps through evaluation of program,
+build translates program to standalone Go source.
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "repl" {
+		return run_repl(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "debug" {
+		return run_debug(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "build" {
+		return run_build(args[1:], stdin, stdout, stderr)
+	}
+	return run_file(args, stdin, stdout, stderr)
+}
+
+func run_file(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lambda", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	trace := flags.Bool("trace", false, "print every reduction step to stderr")
+	entry := flags.String("entry", "", "evaluate definition with this name instead of main term")
+	strict := flags.Bool("strict", false, "use strict grammar (unary abstractions, parenthesized applications)")
+	strategy_name := flags.String("strategy", "normal", "evaluation strategy: "+strings.Join(eval.StrategyNames(), ", ")+
+		" (the first one that backend supports, if not given)")
+	backend_name := flags.String("backend", "tree", "evaluation backend: "+backend_names())
+	limits := add_limit_flags(flags, 0)
+	named := flags.Bool("named", false, "print result with names instead of de bruijn indices")
+	decode := flags.Bool("decode", false, "print numeral result as integer, unless -named is given")
+	disassemble := flags.Bool("disassemble", false, "print bytecode of the program instead of evaluating it")
+	combinators := flags.String("combinators", "", "print the program in combinators of this basis (ski, bckw or turner, with η-rule) instead of evaluating it")
+	numerals := flags.String("numerals", "church", "encoding of numeric literals: church, scott or binary")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+	encoding, ok := numeral.ParseEncoding(*numerals)
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown numeral encoding %s\n", *numerals)
+		return 2
+	}
+	basis, ok := ski.ParseBasis(*combinators)
+	if !ok && *combinators != "" {
+		fmt.Fprintf(stderr, "Unknown combinator basis %s\n", *combinators)
+		return 2
+	}
+	backend, ok := backend_by_name(*backend_name)
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown backend %s\n", *backend_name)
+		return 2
+	}
+	if !is_set(flags, "strategy") {
+		*strategy_name = backend.default_strategy()
+	}
+	strategy, ok := eval.StrategyByName(*strategy_name)
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown strategy %s\n", *strategy_name)
+		return 2
+	}
+	if !backend.supports(*strategy_name) {
+		fmt.Fprintf(stderr, "Backend %s supports only strategies: %s\n", backend.name, strings.Join(backend.strategies, ", "))
+		return 2
+	}
+	if *trace && !backend.traces {
+		fmt.Fprintf(stderr, "Backend %s can't trace reductions, -trace works only with: %s\n", backend.name, tracing_backend_names())
+		return 2
+	}
+
+	filename := "stdin"
+	var text []byte
+	var err error
+	if flags.NArg() == 0 || flags.Arg(0) == "-" {
+		text, err = io.ReadAll(stdin)
+	} else {
+		filename = flags.Arg(0)
+		text, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	logger := util.NewLogger()
+	source_code, result, ok := compile(&logger, parser_mode(*strict), encoding, filename, string(text), *entry)
+	if !ok {
+		report_errors(stderr, &logger)
+		return 1
+	}
+
+	if *disassemble {
+		fmt.Fprint(stdout, vm.Disassemble(vm.Compile(result.Tree, result.Tree.RootId())))
+		return 0
+	}
+	if *combinators != "" {
+		fmt.Fprintln(stdout, ski.Print(source_code, ski.CompileWithEta(result.Tree, result.Tree.RootId(), basis)))
+		return 0
+	}
+	options := limits.options(eval.EvalOptions{Strategy: strategy})
+	if *trace {
+		options.Tracer = trace_tracer{source_code: source_code, w: stderr}
+	}
+	eval_tree, err := backend.eval(context.Background(), result.Tree, result.Tree.RootId(), options)
+	if err != nil {
+		report_limit(stderr, source_code, err)
+		return 1
+	}
+	form := form_index
+	if *named {
+		form = form_named
+	} else if *decode {
+		form = form_numeral
+	}
+	fmt.Fprintln(stdout, readback(source_code, result.VariableNames, encoding, form, eval_tree))
+	return 0
+}
+
+// trace_tracer prints term before every reduction
+type trace_tracer struct {
+	eval.NopTracer
+	source_code source.SourceCode
+	w           io.Writer
+}
+
+func (t trace_tracer) OnRedex(step int, term tree.Tree, redex tree.NodeId) {
+	fmt.Fprintln(t.w, strings.TrimSpace(ast.Print(t.source_code, term, term.RootId())))
+}
+
+type limits struct {
+	reductions, nodes *int
+	timeout           *time.Duration
+}
+
+func add_limit_flags(flags *flag.FlagSet, reductions int) limits {
+	return limits{
+		reductions: flags.Int("max-reductions", reductions, "stop evaluation after this many reductions (0 is no limit)"),
+		nodes:      flags.Int("max-nodes", 0, "stop evaluation when term grows over this many nodes (0 is no limit)"),
+		timeout:    flags.Duration("timeout", 0, "stop evaluation after this time (0 is no limit)"),
+	}
+}
+
+func (l limits) options(options eval.EvalOptions) eval.EvalOptions {
+	options.MaxReductions = *l.reductions
+	options.MaxNodes = *l.nodes
+	options.Timeout = *l.timeout
+	return options
+}
+
+// report_limit prints why evaluation has stopped together with the term it has got to,
+// unless backend keeps no term
+func report_limit(w io.Writer, source_code source.SourceCode, err error) {
+	fmt.Fprintln(w, err)
+	var limit *eval.LimitError
+	if errors.As(err, &limit) && limit.Partial.Count() > 0 {
+		fmt.Fprintln(w, "Partial result:")
+		fmt.Fprintln(w, strings.TrimSpace(ast.Print(source_code, limit.Partial, limit.Partial.RootId())))
+	}
+}
+
+// parse reads module from program text without loading its imports, ok is false if logger got any messages on the way
+func parse(logger *util.Logger, mode parser.Mode, filename, text string) (source_code source.SourceCode, m module.Module, ok bool) {
+	tokenizer := parser.NewTokenizer(logger)
+	source_code = tokenizer.Tokenize(filename, *utf8string.NewString(text))
+	if !logger.IsEmpty() {
+		return
+	}
+
+	parser := parser.NewParserWithMode(logger, mode)
+	m = parser.ParseModule(source_code)
+	ok = logger.IsEmpty()
+	return
+}
+
+// compile runs front end of the interpreter on program text together with modules it imports,
+// entry is the name of definition to evaluate, empty for main term
+func compile(logger *util.Logger, mode parser.Mode, encoding numeral.Encoding, filename, text, entry string) (source_code source.SourceCode, result debruijn.DeBruijnResult, ok bool) {
+	source_code, named_tree, ok := compile_named(logger, mode, filename, text, entry)
+	if !ok {
+		return
+	}
+	result = lower(source_code, named_tree, encoding)
+	return
+}
+
+// compile_named is the part of compile that leaves program as single named tree
+func compile_named(logger *util.Logger, mode parser.Mode, filename, text, entry string) (source_code source.SourceCode, named_tree tree.Tree, ok bool) {
+	source_code, m, ok := loader.Load(logger, mode, filename, text)
+	if !ok {
+		return
+	}
+
+	named_tree = desugar.Program(logger, source_code, m, entry)
+	ok = logger.IsEmpty()
+	return
+}
+
+func lower(source_code source.SourceCode, named_tree tree.Tree, encoding numeral.Encoding) debruijn.DeBruijnResult {
+	core_tree := desugar.DesugarWithNumerals(source_code, named_tree, encoding)
+	return debruijn.ToDeBruijn(source_code, core_tree)
+}
+
+// print_form is how readback prints result
+type print_form int
+
+const (
+	form_index print_form = iota
+	form_named
+	// numeral is printed as integer, other terms as with form_index. Whether λf.λx.x is 0
+	// or False can't be told from the term, so user asks for it
+	form_numeral
+)
+
+var print_forms = [...]string{form_index: "index", form_named: "named", form_numeral: "numeral"}
+
+func (f print_form) String() string {
+	return print_forms[f]
+}
+
+func parse_print_form(name string) (print_form, bool) {
+	for f, n := range print_forms {
+		if n == name {
+			return print_form(f), true
+		}
+	}
+	return form_index, false
+}
+
+// readback prints result of evaluation in form, numerals are decoded with encoding
+func readback(source_code source.SourceCode, variable_names map[int]string, encoding numeral.Encoding, form print_form, t tree.Tree) string {
+	switch form {
+	case form_named:
+		named_source, named_tree := debruijn.FromDeBruijn(source_code, variable_names, t)
+		return ast.PrintNamed(named_source, named_tree, named_tree.RootId())
+	case form_numeral:
+		if n, ok := numeral.Decode(encoding, t, t.RootId()); ok {
+			return strconv.Itoa(n)
+		}
+	}
+	return strings.TrimSpace(ast.Print(source_code, t, t.RootId()))
+}
+
+// is_set tells whether flag was given on the command line rather than left default
+func is_set(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func parser_mode(strict bool) parser.Mode {
+	if strict {
+		return parser.ModeStrict
+	}
+	return parser.ModeNonStrict
+}
+
+func report_errors(w io.Writer, logger *util.Logger) {
+	for {
+		m, ok := logger.Next()
+		if !ok {
+			break
+		}
+		fmt.Fprintln(w, m)
+	}
+}
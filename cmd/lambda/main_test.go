@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"lambda/ast/sexpr"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testRun(args []string, stdin string) (code int, stdout, stderr string) {
+	out, err := bytes.Buffer{}, bytes.Buffer{}
+	code = run(args, strings.NewReader(stdin), &out, &err)
+	return code, out.String(), err.String()
+}
+
+func TestRunStdin(test *testing.T) {
+	code, stdout, stderr := testRun(nil, `((λu.λv.(u x)) y)`)
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if sexpr.Minified(stdout) != `(λ(2 1))` {
+		test.Errorf("Unexpected output %q", stdout)
+	}
+}
+
+func TestRunFile(test *testing.T) {
+	path := filepath.Join(test.TempDir(), "ski.lc")
+	text := `
+    let K = λx.λy.x in
+    let S = λx.λy.λz.((x z) (y z)) in
+    ((S K) K)
+    `
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		test.Fatal(err)
+	}
+	code, stdout, stderr := testRun([]string{path}, "")
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if sexpr.Minified(stdout) != `(λ 0)` {
+		test.Errorf("Unexpected output %q", stdout)
+	}
+}
+
+func TestRunSyntaxError(test *testing.T) {
+	for _, text := range []string{``, `)`, `(λx.x`, `λ`} {
+		code, stdout, stderr := testRun(nil, text)
+		if code == 0 || stdout != "" {
+			test.Errorf("Expected failure on %q, got %q", text, stdout)
+		}
+		if !strings.Contains(stderr, "Fatal at stdin") {
+			test.Errorf("Expected diagnostics on %q, got %q", text, stderr)
+		}
+	}
+}
+
+func TestRunMissingFile(test *testing.T) {
+	code, _, _ := testRun([]string{filepath.Join(test.TempDir(), "nope.lc")}, "")
+	if code == 0 {
+		test.Error("Expected failure on missing file")
+	}
+}
+
+func TestRunParserMode(test *testing.T) {
+	text := `(λx y.x) a b`
+	code, stdout, stderr := testRun(nil, text)
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if sexpr.Minified(stdout) != `0` {
+		test.Errorf("Unexpected output %q", stdout)
+	}
+	if code, _, _ := testRun([]string{"-strict"}, text); code == 0 {
+		test.Error("Expected strict grammar to reject juxtaposition")
+	}
+}
+
+func TestRunEntry(test *testing.T) {
+	text := `
+def True = λt f.t
+def False = λt f.f
+def Not = λp.p False True
+def Main = Not (Not True)
+Not True
+`
+	code, stdout, stderr := testRun([]string{"-entry", "Main"}, text)
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if sexpr.Minified(stdout) != `(λ(λ 1))` {
+		test.Errorf("Unexpected output %q", stdout)
+	}
+	code, stdout, stderr = testRun(nil, text)
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if sexpr.Minified(stdout) != `(λ(λ 0))` {
+		test.Errorf("Unexpected output %q", stdout)
+	}
+	if code, _, _ := testRun([]string{"-entry", "Nope"}, text); code == 0 {
+		test.Error("Expected failure on unknown entry")
+	}
+}
+
+func TestRunImport(test *testing.T) {
+	dir := test.TempDir()
+	files := map[string]string{
+		"bool.lc": "def True = λt f.t\ndef False = λt f.f\n",
+		"main.lc": "import \"bool.lc\" as B\ndef Not = λp.p B.False B.True\nNot B.True\n",
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			test.Fatal(err)
+		}
+	}
+	code, stdout, stderr := testRun([]string{filepath.Join(dir, "main.lc")}, "")
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if sexpr.Minified(stdout) != `(λ(λ 0))` {
+		test.Errorf("Unexpected output %q", stdout)
+	}
+}
+
+func TestRunNumerals(test *testing.T) {
+	cases := [...]struct {
+		args           []string
+		text, expected string
+	}{
+		{[]string{"-decode"}, `(λn f x.f (n f x)) 4`, `5`},
+		{[]string{"-decode"}, `(λm n f.m (n f)) 3 4`, `12`},
+		{[]string{"-decode", "-numerals", "scott"}, `(λn s z.s n) 2`, `3`},
+		{[]string{"-decode", "-numerals", "binary"}, `(λx.x) 6`, `6`},
+		{[]string{"-decode"}, `(λx.x) λf x.f x`, `1`},
+		{[]string{"-decode"}, `(λx.x) λf.f`, `(λ 0)`},
+		// numerals are printed as terms unless asked for
+		{nil, `(λn f x.f (n f x)) 0`, `(λ(λ( 1 0)))`},
+		// False is 0 of Church numerals
+		{[]string{"-named"}, "def Odd = λn.n (λb t f.b f t) (λt f.f)\nOdd 4", `λt.λf.f`},
+		{[]string{"-named", "-decode"}, `(λx.x) 0`, `λx.λx1.x1`},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := testRun(c.args, c.text)
+		if code != 0 {
+			test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+		}
+		if sexpr.Minified(stdout) != sexpr.Minified(c.expected) {
+			test.Errorf("%s: expected %s got %q", c.text, c.expected, stdout)
+		}
+	}
+	if code, _, _ := testRun([]string{"-numerals", "roman"}, `1`); code == 0 {
+		test.Error("Expected failure on unknown encoding")
+	}
+}
+
+func TestRunNamed(test *testing.T) {
+	text := `
+def Compose = λf g x.f (g x)
+Compose (λa.a) (λb.b y)
+`
+	code, stdout, stderr := testRun([]string{"-named"}, text)
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if stdout != "λx.(x y)\n" {
+		test.Errorf("Unexpected output %q", stdout)
+	}
+}
+
+func TestRunStrategy(test *testing.T) {
+	text := `(λx y.x) ((λz.z) a)`
+	code, stdout, stderr := testRun([]string{"-strategy", "whnf"}, text)
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if sexpr.Minified(stdout) != sexpr.Minified(`(λ((λ 0) 1))`) {
+		test.Errorf("Unexpected output %q", stdout)
+	}
+	if code, _, _ := testRun([]string{"-strategy", "lazy"}, text); code == 0 {
+		test.Error("Expected failure on unknown strategy")
+	}
+}
+
+func TestRunLimits(test *testing.T) {
+	text := `(λx.(x x)) (λx.(x x))`
+	code, _, stderr := testRun([]string{"-max-reductions", "10"}, text)
+	if code != 1 {
+		test.Fatalf("Expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr, "reduction limit") || !strings.Contains(stderr, "Partial result") {
+		test.Errorf("Unexpected stderr %q", stderr)
+	}
+	if code, _, stderr := testRun([]string{"-timeout", "10ms"}, text); code != 1 || !strings.Contains(stderr, "time limit") {
+		test.Errorf("Unexpected exit code %d, stderr %q", code, stderr)
+	}
+	// backend that keeps no term has no partial result
+	code, _, stderr = testRun([]string{"-backend", "need", "-max-reductions", "10"}, text)
+	if code != 1 || !strings.Contains(stderr, "reduction limit") || strings.Contains(stderr, "Partial result") {
+		test.Errorf("Unexpected exit code %d, stderr %q", code, stderr)
+	}
+}
+
+func TestRunBackend(test *testing.T) {
+	text := `(λx y.x) ((λz.z) a)`
+	code, stdout, stderr := testRun([]string{"-backend", "krivine", "-strategy", "whnf"}, text)
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if sexpr.Minified(stdout) != sexpr.Minified(`(λ((λ 0) 1))`) {
+		test.Errorf("Unexpected output %q", stdout)
+	}
+	// strategy defaults to the one that backend supports
+	code, stdout, stderr = testRun([]string{"-backend", "krivine"}, text)
+	if code != 0 || sexpr.Minified(stdout) != sexpr.Minified(`(λ((λ 0) 1))`) {
+		test.Errorf("Unexpected exit code %d, output %q, stderr:\n%s", code, stdout, stderr)
+	}
+	if code, _, _ := testRun([]string{"-backend", "krivine", "-strategy", "normal"}, text); code != 2 {
+		test.Error("Expected failure on strategy that backend doesn't support")
+	}
+	for _, b := range [...]string{"need", "parallel", "vm", "nbe", "ski", "inet", "race"} {
+		code, stdout, stderr = testRun([]string{"-backend", b}, `(λx.(x x)) ((λy.y) λz.z)`)
+		if code != 0 || sexpr.Minified(stdout) != sexpr.Minified(`(λ 0)`) {
+			test.Errorf("%s: unexpected exit code %d, output %q, stderr:\n%s", b, code, stdout, stderr)
+		}
+	}
+	code, _, stderr = testRun([]string{"-backend", "parallel", "-trace"}, text)
+	if code != 0 || stderr == "" {
+		test.Errorf("Expected trace of parallel backend, exit code %d, stderr %q", code, stderr)
+	}
+	for _, b := range [...]string{"krivine", "vm", "ski", "inet"} {
+		if code, _, stderr := testRun([]string{"-backend", b, "-trace"}, text); code != 2 || !strings.Contains(stderr, "can't trace") {
+			test.Errorf("%s: expected -trace to be rejected, exit code %d, stderr %q", b, code, stderr)
+		}
+	}
+	code, stdout, _ = testRun([]string{"-disassemble"}, `λx.x`)
+	if code != 0 || stdout != ">0000  GRAB\n 0001  ACCESS 0\n" {
+		test.Errorf("Unexpected disassembly %q", stdout)
+	}
+	code, stdout, _ = testRun([]string{"-combinators", "turner"}, `λx y z.(x z (y z))`)
+	if code != 0 || stdout != "S\n" {
+		test.Errorf("Unexpected combinators %q", stdout)
+	}
+	if code, _, _ := testRun([]string{"-backend", "magic"}, text); code != 2 {
+		test.Error("Expected failure on unknown backend")
+	}
+}
+
+func TestRunBuild(test *testing.T) {
+	code, stdout, stderr := testRun([]string{"build"}, `λx.(x y)`)
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, "// Code generated by lambda build") || !strings.Contains(stdout, "free(0)") {
+		test.Errorf("Unexpected output %q", stdout)
+	}
+
+	path := filepath.Join(test.TempDir(), "main.go")
+	if code, _, stderr := testRun([]string{"build", "-decode", "-numerals", "scott", "-o", path}, `3`); code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if !strings.Contains(string(text), "= decode_scott") {
+		test.Errorf("Expected numeral decoder in\n%s", text)
+	}
+	if code, _, _ := testRun([]string{"build"}, `(λx.x`); code != 1 {
+		test.Error("Expected failure on syntax error")
+	}
+}
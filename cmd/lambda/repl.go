@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"lambda/eval"
+	"lambda/middle/numeral"
+	"lambda/syntax/loader"
+	"lambda/syntax/parser"
+	"lambda/syntax/source"
+	"lambda/util"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/utf8string"
+)
+
+const repl_help = `Enter a term to evaluate it, or "def <name> = <term>" (as well as "let <name> = <term>")
+to define name for later inputs. Redefinition replaces previous one everywhere.
+Imports (import "<file>" [as <alias>]) are resolved relative to working directory.
+Input continues on the next line while parens are left open, empty line ends it.
+
+Commands:
+    :load <file>      add definitions of module file and evaluate its main term
+    :env              list imports and definitions in scope
+    :reset            forget all imports and definitions
+    :strategy [name]  show or set evaluation strategy
+    :numerals [name]  show or set encoding of numeric literals (church, scott, binary)
+    :print [form]     show or set form of results: index (de bruijn), named or numeral (integer)
+    :help             show this message
+    :quit             leave the repl
+`
+
+const (
+	repl_prompt              = "λ> "
+	repl_continuation_prompt = ".. "
+	repl_filename            = "repl"
+	// divergent input shouldn't hang the session
+	repl_max_reductions = 1000000
+)
+
+var repl_strategies = map[string]string{
+	"normal":      "normal order to normal form",
+	"whnf":        "call by name to weak head normal form",
+	"hnf":         "call by name to head normal form",
+	"applicative": "applicative order to normal form",
+	"cbv":         "call by value to weak head normal form",
+}
+
+type definition struct {
+	name, value string
+}
+
+type repl struct {
+	imports     []string // import lines with absolute paths
+	definitions []definition
+	mode        parser.Mode
+	numerals    numeral.Encoding
+	form        print_form
+	strategy    string
+	limits      limits
+	stdout      io.Writer
+	stderr      io.Writer
+}
+
+func run_repl(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lambda repl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: lambda repl [flags] [file...]")
+		fmt.Fprintln(stderr, "Files are loaded as with :load before the session starts")
+		flags.PrintDefaults()
+	}
+	strict := flags.Bool("strict", false, "use strict grammar (unary abstractions, parenthesized applications)")
+	limits := add_limit_flags(flags, repl_max_reductions)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	r := repl{mode: parser_mode(*strict), strategy: "normal", limits: limits, stdout: stdout, stderr: stderr}
+	for _, filename := range flags.Args() {
+		if !r.load(filename) {
+			return 1
+		}
+	}
+	r.loop(stdin)
+	return 0
+}
+
+// loop reads entries from input until it is exhausted or :quit is entered,
+// entry spans several lines while it has unclosed parens
+func (r *repl) loop(input io.Reader) (quit bool) {
+	prompt := func(s string) {
+		fmt.Fprint(r.stdout, s)
+	}
+
+	scanner := bufio.NewScanner(input)
+	entry := strings.Builder{}
+	depth := 0
+	prompt(repl_prompt)
+	for scanner.Scan() {
+		line := scanner.Text()
+		entry.WriteString(line)
+		entry.WriteByte('\n')
+		depth += strings.Count(line, string(source.TokenLeftParenRune)) -
+			strings.Count(line, string(source.TokenRightParenRune))
+		if depth > 0 && strings.TrimSpace(line) != "" {
+			prompt(repl_continuation_prompt)
+			continue
+		}
+
+		quit = r.handle(entry.String())
+		if quit {
+			return
+		}
+		entry.Reset()
+		depth = 0
+		prompt(repl_prompt)
+	}
+	if entry.Len() > 0 {
+		quit = r.handle(entry.String())
+	}
+	return
+}
+
+func (r *repl) handle(entry string) (quit bool) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return
+	}
+	if strings.HasPrefix(entry, ":") {
+		return r.command(entry)
+	}
+	if name, value, ok := r.definition(entry); ok {
+		if r.validate(name, value) {
+			r.define(name, value)
+		}
+		return
+	}
+	r.run(repl_filename, entry)
+	return
+}
+
+// run adds imports and definitions of module text and evaluates its main term if there is one
+func (r *repl) run(filename, text string) bool {
+	logger := util.NewLogger()
+	if _, _, ok := loader.Load(&logger, r.mode, filename, text); !ok {
+		report_errors(r.stderr, &logger)
+		return false
+	}
+	source_code, m, _ := parse(&logger, r.mode, filename, text)
+
+	for _, imp := range m.Imports {
+		path := imp.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(filename), path)
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		line := "import \"" + path + "\""
+		if imp.Alias != "" {
+			line += " as " + imp.Alias
+		}
+		r.add_import(line)
+	}
+	for _, d := range m.Definitions {
+		r.define(d.Name, strings.TrimSpace(source_code.Text(d.Start, d.End)))
+	}
+	if m.HasMain() {
+		r.evaluate(source_code.Text(m.MainStart, source.TokenId(source_code.TokenCount()-1)))
+	}
+	return true
+}
+
+func (r *repl) add_import(line string) {
+	for _, i := range r.imports {
+		if i == line {
+			return
+		}
+	}
+	r.imports = append(r.imports, line)
+}
+
+func (r *repl) define(name, value string) {
+	for i := range r.definitions {
+		if r.definitions[i].name == name {
+			r.definitions[i].value = value
+			return
+		}
+	}
+	r.definitions = append(r.definitions, definition{name: name, value: value})
+}
+
+func (r *repl) command(entry string) (quit bool) {
+	fields := strings.Fields(entry)
+	switch fields[0] {
+	case ":quit", ":q":
+		return true
+	case ":help", ":h":
+		fmt.Fprint(r.stdout, repl_help)
+	case ":load", ":l":
+		if len(fields) != 2 {
+			fmt.Fprintln(r.stderr, "Usage: :load <file>")
+			return
+		}
+		r.load(fields[1])
+	case ":env":
+		for _, i := range r.imports {
+			fmt.Fprintln(r.stdout, i)
+		}
+		for _, d := range r.definitions {
+			fmt.Fprintf(r.stdout, "def %s = %s\n", d.name, d.value)
+		}
+	case ":reset":
+		r.imports = nil
+		r.definitions = nil
+	case ":strategy":
+		if len(fields) == 1 {
+			fmt.Fprintf(r.stdout, "%s (%s)\n", r.strategy, repl_strategies[r.strategy])
+			return
+		}
+		if _, ok := eval.StrategyByName(fields[1]); !ok {
+			fmt.Fprintf(r.stderr, "Unknown strategy %s, available are: %s\n", fields[1], strings.Join(eval.StrategyNames(), ", "))
+			return
+		}
+		r.strategy = fields[1]
+	case ":numerals":
+		if len(fields) == 1 {
+			fmt.Fprintln(r.stdout, r.numerals)
+			return
+		}
+		encoding, ok := numeral.ParseEncoding(fields[1])
+		if !ok {
+			fmt.Fprintf(r.stderr, "Unknown numeral encoding %s, available are: church, scott, binary\n", fields[1])
+			return
+		}
+		r.numerals = encoding
+	case ":print":
+		if len(fields) == 1 {
+			fmt.Fprintln(r.stdout, r.form)
+			return
+		}
+		form, ok := parse_print_form(fields[1])
+		if !ok {
+			fmt.Fprintf(r.stderr, "Unknown form %s, available are: %s\n", fields[1], strings.Join(print_forms[:], ", "))
+			return
+		}
+		r.form = form
+	default:
+		fmt.Fprintf(r.stderr, "Unknown command %s, see :help\n", fields[0])
+	}
+	return
+}
+
+func (r *repl) load(filename string) bool {
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(r.stderr, err)
+		return false
+	}
+	return r.run(filename, string(text))
+}
+
+// definition recognizes top level binding "let <name> = <term>" that lacks
+// "in" part, which would make it an ordinary term
+func (r *repl) definition(entry string) (name, value string, ok bool) {
+	logger := util.NewLogger()
+	text := utf8string.NewString(entry)
+	tokenizer := parser.NewTokenizer(&logger)
+	source_code := tokenizer.Tokenize(repl_filename, *text)
+	if !logger.IsEmpty() || source_code.TokenCount() < 5 {
+		return
+	}
+	is := func(id source.TokenId, lexeme string) bool {
+		return source_code.Token(id).Tag == source.TokenIdentifier &&
+			(lexeme == "" || source_code.Lexeme(id) == lexeme)
+	}
+	if !is(0, "let") || !is(1, "") || !is(2, "=") {
+		return
+	}
+	if _, _, parsed := parse(&logger, r.mode, repl_filename, entry); parsed {
+		return
+	}
+	value_start := source_code.Token(3).Start
+	return source_code.Lexeme(1), text.Slice(value_start, text.RuneCount()), true
+}
+
+// validate checks syntax of value on its own, so reported locations match what user typed,
+// then checks that names it refers to are resolved among imports and definitions
+func (r *repl) validate(name, value string) bool {
+	logger := util.NewLogger()
+	if _, _, ok := parse(&logger, r.mode, repl_filename, value); !ok {
+		report_errors(r.stderr, &logger)
+		return false
+	}
+	program := r.program(name, fmt.Sprintf("def %s = %s\n", name, value))
+	if _, _, ok := compile(&logger, r.mode, r.numerals, repl_filename, program, name); !ok {
+		report_errors(r.stderr, &logger)
+		return false
+	}
+	return true
+}
+
+// program puts imports and definitions in scope of text, except definition that is replaced by text
+func (r *repl) program(except, text string) string {
+	program := strings.Builder{}
+	for _, i := range r.imports {
+		fmt.Fprintln(&program, i)
+	}
+	for _, d := range r.definitions {
+		if d.name != except {
+			fmt.Fprintf(&program, "def %s = %s\n", d.name, d.value)
+		}
+	}
+	program.WriteString(text)
+	return program.String()
+}
+
+func (r *repl) evaluate(term string) {
+	logger := util.NewLogger()
+	source_code, result, ok := compile(&logger, r.mode, r.numerals, repl_filename, r.program("", term), "")
+	if !ok {
+		report_errors(r.stderr, &logger)
+		return
+	}
+	strategy, _ := eval.StrategyByName(r.strategy)
+	options := r.limits.options(eval.EvalOptions{Strategy: strategy})
+	eval_tree, err := eval.Eval(context.Background(), result.Tree, result.Tree.RootId(), options)
+	if err != nil {
+		report_limit(r.stderr, source_code, err)
+		return
+	}
+	fmt.Fprintln(r.stdout, readback(source_code, result.VariableNames, r.numerals, r.form, eval_tree))
+}
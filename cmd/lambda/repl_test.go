@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplDefinitions(test *testing.T) {
+	input := `
+def K = λx.λy.x
+let I = λx.x
+((K I)
+    z)
+let x = y in x
+`
+	code, stdout, stderr := testRun([]string{"repl"}, input)
+	if code != 0 || stderr != "" {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "(λ 0)") {
+		test.Errorf("Expected (K I) z to reduce to I, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, repl_prompt+"0\n") {
+		test.Errorf("Expected let expression to be evaluated, got:\n%s", stdout)
+	}
+}
+
+func TestReplCommands(test *testing.T) {
+	path := filepath.Join(test.TempDir(), "prelude.lc")
+	prelude := `
+-- main term of loaded file is evaluated too
+def Not = λp.p False True
+def True = λt f.t
+def False = λt f.f
+Not False
+`
+	if err := os.WriteFile(path, []byte(prelude), 0o644); err != nil {
+		test.Fatal(err)
+	}
+	input := `
+:load ` + path + `
+let False = λa.λb.b
+:env
+(Not False)
+:reset
+:env
+:strategy
+:quit
+(Not True)
+`
+	code, stdout, stderr := testRun([]string{"repl"}, input)
+	if code != 0 || stderr != "" {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	env := "def Not = λp.p False True\ndef True = λt f.t\ndef False = λa.λb.b\n"
+	if strings.Count(stdout, env) != 1 || strings.Count(stdout, "def ") != 3 {
+		test.Errorf("Expected single listing of environment, got:\n%s", stdout)
+	}
+	if strings.Count(stdout, "(λ(λ 1))") != 2 {
+		test.Errorf("Expected (Not False) to be True, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "normal") {
+		test.Errorf("Expected current strategy, got:\n%s", stdout)
+	}
+	if strings.Count(stdout, "(λ(λ") != 2 {
+		test.Errorf("Expected input after :quit to be ignored, got:\n%s", stdout)
+	}
+}
+
+func TestReplPrint(test *testing.T) {
+	input := `
+(λn f x.f (n f x)) 2
+:print numeral
+(λn f x.f (n f x)) 2
+λt f.f
+:print
+:print roman
+`
+	code, stdout, stderr := testRun([]string{"repl"}, input)
+	if code != 0 {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, repl_prompt+"(λ(λ( 1( 1( 1 0)))))\n") || !strings.Contains(stdout, repl_prompt+"3\n") {
+		test.Errorf("Expected numeral to be decoded only after :print numeral, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, repl_prompt+"0\n") || !strings.Contains(stdout, repl_prompt+"numeral\n") {
+		test.Errorf("Expected False to be printed as 0 in numeral form, got:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "Unknown form roman") {
+		test.Errorf("Expected unknown form to be reported, got:\n%s", stderr)
+	}
+}
+
+func TestReplErrors(test *testing.T) {
+	input := `
+let x = )
+(y
+
+:strategy nope
+:nope
+`
+	_, stdout, stderr := testRun([]string{"repl"}, input)
+	if !strings.Contains(stderr, "Fatal at repl:1") ||
+		!strings.Contains(stderr, "Unknown strategy nope") ||
+		!strings.Contains(stderr, "Unknown command :nope") {
+		test.Errorf("Expected diagnostics, got:\n%s", stderr)
+	}
+	if strings.Contains(stdout, "let") {
+		test.Errorf("Expected invalid definition to be dropped, got:\n%s", stdout)
+	}
+}
+
+func TestReplModuleEntry(test *testing.T) {
+	input := `
+def I = λx.x def Self = λx.x x
+Self I
+def Id = Self I
+Id y
+`
+	code, stdout, stderr := testRun([]string{"repl"}, input)
+	if code != 0 || stderr != "" {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if strings.Count(stdout, "(λ 0)") != 1 || !strings.Contains(stdout, repl_prompt+"0\n") {
+		test.Errorf("Unexpected output:\n%s", stdout)
+	}
+}
+
+func TestReplImport(test *testing.T) {
+	dir := test.TempDir()
+	files := map[string]string{
+		"bool.lc": "def True = λt f.t\ndef False = λt f.f\n",
+		"not.lc":  "import \"bool.lc\" as B\ndef Not = λp.p B.False B.True\n",
+		// main term right after imports
+		"main.lc": "import \"bool.lc\" as B\nB.False a\n",
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			test.Fatal(err)
+		}
+	}
+	input := `
+:load ` + filepath.Join(dir, "not.lc") + `
+let Id = λx.B.True
+Not (Id x)
+:env
+:load ` + filepath.Join(dir, "main.lc") + `
+`
+	code, stdout, stderr := testRun([]string{"repl"}, input)
+	if code != 0 || stderr != "" {
+		test.Fatalf("Exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "(λ(λ 0))") {
+		test.Errorf("Expected Not True to reduce to False, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, repl_prompt+"(λ 0)\n") {
+		test.Errorf("Expected main term of file to be evaluated, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "import \""+filepath.Join(dir, "bool.lc")+"\" as B\n") {
+		test.Errorf("Expected import with absolute path in environment, got:\n%s", stdout)
+	}
+}
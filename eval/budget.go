@@ -0,0 +1,113 @@
+package eval
+
+import (
+	"context"
+	"lambda/ast/tree"
+)
+
+// context is checked once in this many steps of abstract machines
+const poll_period = 1024
+
+// recursion of evaluators is stopped at this depth, well before Go stack overflows,
+// which can't be recovered from
+const max_depth = 1 << 18
+
+// limit_stop unwinds evaluation that is done by recursion when a limit is hit
+type limit_stop struct {
+	limit *LimitError
+}
+
+// Budget checks limits of options for evaluators that run the whole term at once, like
+// abstract machines, which have no term to stop at between steps. When a limit is hit,
+// the evaluator is unwound by panic, and Run returns it as LimitError
+type Budget struct {
+	ctx        context.Context
+	options    EvalOptions
+	reductions int
+	ticks      int
+	depth      int
+}
+
+func NewBudget(ctx context.Context, options EvalOptions) *Budget {
+	return &Budget{ctx: ctx, options: options}
+}
+
+// Run calls f with Timeout of options applied. Limit that f hits is returned
+// as *LimitError, its Partial term is empty
+func (b *Budget) Run(f func()) (err error) {
+	if b.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		b.ctx, cancel = context.WithTimeout(b.ctx, b.options.Timeout)
+		defer cancel()
+	}
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		stop, ok := r.(limit_stop)
+		if !ok {
+			panic(r)
+		}
+		stop.limit.Reductions = b.reductions
+		err = stop.limit
+	}()
+	f()
+	return nil
+}
+
+func (b *Budget) Reductions() int {
+	return b.reductions
+}
+
+// Tick counts step of work, context is checked once in poll_period of them
+func (b *Budget) Tick() {
+	b.ticks++
+	if b.ticks%poll_period == 0 {
+		if err := b.ctx.Err(); err != nil {
+			panic(limit_stop{LimitFromContext(err)})
+		}
+	}
+}
+
+// Reduce counts β-reduction that is about to be done, it is a step of work too
+func (b *Budget) Reduce() {
+	if b.options.MaxReductions > 0 && b.reductions >= b.options.MaxReductions {
+		panic(limit_stop{&LimitError{Limit: LimitReductions}})
+	}
+	b.reductions++
+	b.Tick()
+}
+
+// Enter counts nested call of evaluator that recurses on Go stack, Leave is its pair
+func (b *Budget) Enter() {
+	b.depth++
+	if b.depth > max_depth {
+		panic(limit_stop{&LimitError{Limit: LimitDepth}})
+	}
+}
+
+func (b *Budget) Leave() {
+	b.depth--
+}
+
+// Nodes checks size of evaluator's memory, whatever it counts, against MaxNodes
+func (b *Budget) Nodes(count int) {
+	if b.options.MaxNodes > 0 && count > b.options.MaxNodes {
+		panic(limit_stop{&LimitError{Limit: LimitNodes}})
+	}
+}
+
+// EvalWhole makes backend out of evaluator that reduces term to normal form with
+// budget. The backend has the contract of Eval with NormalOrder, except that Tracer gets only
+// OnDone, and on limit both result and Partial term are empty, as the evaluator keeps no tree
+func EvalWhole(ctx context.Context, options EvalOptions, evaluate func(b *Budget) tree.Tree) (result tree.Tree, err error) {
+	b := NewBudget(ctx, options)
+	err = b.Run(func() {
+		result = evaluate(b)
+	})
+	if options.Tracer != nil {
+		options.Tracer.OnDone(b.reductions, result)
+	}
+	return result, err
+}
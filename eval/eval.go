@@ -1,8 +1,12 @@
 package eval
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"lambda/ast/ast"
 	"lambda/ast/tree"
+	"time"
 )
 
 func replicate_subtree(t *tree.MutableTree, root tree.NodeId) (new_root tree.NodeId) {
@@ -80,7 +84,7 @@ func shift_indicies(t *tree.MutableTree, in tree.NodeId, cutoff, amount int) {
 	}
 }
 
-func substitute(t *tree.MutableTree, in tree.NodeId, expr tree.NodeId, level int) {
+func substitute(t *tree.MutableTree, in tree.NodeId, expr tree.NodeId, level int, on_substitute func(tree.NodeId)) {
 	node := t.Node(in)
 	switch node.Tag {
 	case tree.NodeIndexVariable:
@@ -89,97 +93,143 @@ func substitute(t *tree.MutableTree, in tree.NodeId, expr tree.NodeId, level int
 		if index == level {
 			expr_cloned := t.Node(replicate_subtree(t, expr))
 			t.SetNode(in, expr_cloned)
+			on_substitute(in)
 		}
 	case tree.NodePureAbstraction:
 		v := ast.ToPureAbstractionNode(t.Tree, node)
 		body := v.Body()
 		shift_indicies(t, expr, 0, 1)
-		substitute(t, body, expr, level+1)
+		substitute(t, body, expr, level+1, on_substitute)
+		shift_indicies(t, expr, 0, -1)
 	case tree.NodeApplication:
 		v := ast.ToApplicationNode(t.Tree, node)
-		substitute(t, v.Lhs(), expr, level)
-		substitute(t, v.Rhs(), expr, level)
+		substitute(t, v.Lhs(), expr, level, on_substitute)
+		substitute(t, v.Rhs(), expr, level, on_substitute)
 	default:
 		panic("unreachable")
 	}
 }
 
-func find_redex_whnf(t tree.Tree, expr tree.NodeId) tree.NodeId {
-	expr_node := t.Node(expr)
-	if expr_node.Tag == tree.NodeApplication {
-		v := ast.ToApplicationNode(t, expr_node)
-		cur := v.Lhs()
-		cur_node := t.Node(cur)
-		for cur_node.Tag == tree.NodeApplication {
-			cur_app := ast.ToApplicationNode(t, cur_node)
-
-			new_cur := cur_app.Lhs()
-			expr = cur
-			cur = new_cur
-			cur_node = t.Node(cur)
-		}
-		if cur_node.Tag == tree.NodePureAbstraction {
-			return expr
-		}
-	}
-	return tree.NodeNull
+// Tracer observes evaluation. Trees and node ids passed to it are valid only during the call,
+// since evaluation mutates tree in place and garbage collection renumbers nodes
+type Tracer interface {
+	// Redex is about to be contracted, step counts from 1
+	OnRedex(step int, t tree.Tree, redex tree.NodeId)
+	// Occurrence of bound variable was replaced with copy of argument
+	OnSubstitute(step int, occurrence tree.NodeId, argument tree.NodeId)
+	// Node count before and after garbage collection
+	OnGC(before, after int)
+	// Evaluation is finished, result is partial if some limit was hit
+	OnDone(reductions int, result tree.Tree)
 }
 
-func find_redex_nf(t tree.Tree, expr tree.NodeId) tree.NodeId {
-	var aux func(tree.NodeId) tree.NodeId
-	aux = func(id tree.NodeId) tree.NodeId {
-		n := t.Node(id)
-		switch n.Tag {
-		case tree.NodeApplication:
-			app := ast.ToApplicationNode(t, n)
-			lhs := t.Node(app.Lhs())
-			switch lhs.Tag {
-			case tree.NodeApplication:
-				return aux(app.Lhs())
-			case tree.NodePureAbstraction:
-				return id
-			case tree.NodeIndexVariable:
-				return aux(app.Rhs())
-			default:
-				panic("unreachable")
-			}
-		case tree.NodePureAbstraction:
-			return aux(ast.ToPureAbstractionNode(t, n).Body())
-		case tree.NodeIndexVariable:
-			return tree.NodeNull
-		default:
-			panic("unreachable")
-		}
-	}
-	return aux(expr)
+// NopTracer ignores everything, embed it to implement only needed callbacks
+type NopTracer struct{}
+
+func (NopTracer) OnRedex(int, tree.Tree, tree.NodeId)        {}
+func (NopTracer) OnSubstitute(int, tree.NodeId, tree.NodeId) {}
+func (NopTracer) OnGC(int, int)                              {}
+func (NopTracer) OnDone(int, tree.Tree)                      {}
+
+type EvalOptions struct {
+	// NormalOrder if nil
+	Strategy Strategy
+	// NopTracer if nil
+	Tracer Tracer
+	// Limits are off when zero
+	MaxReductions int
+	MaxNodes      int
+	Timeout       time.Duration
 }
 
-func Eval(log_eval func(t tree.Tree), in_tree tree.Tree, root tree.NodeId) tree.Tree {
+type Limit int
+
+const (
+	LimitReductions Limit = iota
+	LimitNodes
+	// Timeout of options or deadline of context
+	LimitTime
+	// Context was canceled
+	LimitCanceled
+	// Evaluator that recurses on Go stack went too deep, see Budget.Enter
+	LimitDepth
+)
 
-	t := tree.NewMutableTree(in_tree.Clone())
-	for reductions_count := 1; true; reductions_count++ {
-		app_id := find_redex_nf(t.Tree, root)
-		if app_id == tree.NodeNull {
-			break
-		}
+var limit_names = [...]string{
+	LimitReductions: "reduction limit reached",
+	LimitNodes:      "node limit reached",
+	LimitTime:       "time limit reached",
+	LimitCanceled:   "evaluation canceled",
+	LimitDepth:      "recursion depth limit reached",
+}
+
+func (l Limit) String() string {
+	return limit_names[l]
+}
+
+// LimitError is returned when evaluation is stopped before strategy is done
+type LimitError struct {
+	Limit      Limit
+	Reductions int
+	// Term as it was when evaluation stopped
+	Partial tree.Tree
+	// Error of context, if it is the reason
+	Cause error
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("Evaluation stopped after %d reductions: %s", e.Reductions, e.Limit)
+}
 
-		log_eval(t.Tree)
+func (e *LimitError) Unwrap() error {
+	return e.Cause
+}
+
+// LimitFromContext is LimitError for error of context: LimitTime if deadline is exceeded,
+// LimitCanceled otherwise. Reductions and Partial term are left to the caller
+func LimitFromContext(err error) *LimitError {
+	limit := LimitCanceled
+	if errors.Is(err, context.DeadlineExceeded) {
+		limit = LimitTime
+	}
+	return &LimitError{Limit: limit, Cause: err}
+}
 
-		app := ast.ToApplicationNode(t.Tree, t.Node(app_id))
-		lambda := ast.ToPureAbstractionNode(t.Tree, t.Node(app.Lhs()))
-		app_rhs := app.Rhs()
-		lambda_body := lambda.Body()
+// Eval reduces term until strategy finds no redex or some limit is hit, then
+// error is *LimitError with partial term
+func Eval(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options EvalOptions) (tree.Tree, error) {
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
 
-		shift_indicies(&t, app_rhs, 0, 1)
-		substitute(&t, lambda_body, app_rhs, 0)
-		shift_indicies(&t, lambda_body, 0, -1)
+	e := NewEvaluator(in_tree, root, options)
+	stop := func(limit *LimitError) (tree.Tree, error) {
+		e.CollectGarbage()
+		limit.Reductions, limit.Partial = e.Reductions(), e.Tree()
+		e.tracer.OnDone(e.Reductions(), e.Tree())
+		return e.Tree(), limit
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return stop(LimitFromContext(err))
+		}
+		if options.MaxNodes > 0 && e.Tree().Count() > options.MaxNodes {
+			e.CollectGarbage()
+			if e.Tree().Count() > options.MaxNodes {
+				return stop(&LimitError{Limit: LimitNodes})
+			}
+		}
 
-		t.SetNode(app_id, t.Node(lambda_body))
-		if reductions_count%10 == 0 {
-			collect_garbage(&t, root)
-			root = t.RootId()
+		if e.Done() {
+			break
+		}
+		if options.MaxReductions > 0 && e.Reductions() >= options.MaxReductions {
+			return stop(&LimitError{Limit: LimitReductions})
 		}
+		e.Step()
 	}
-	// collect_garbage(&t, root)
-	return t.Tree
+	e.tracer.OnDone(e.Reductions(), e.Tree())
+	return e.Tree(), nil
 }
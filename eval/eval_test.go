@@ -1,12 +1,13 @@
 package eval
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"lambda/ast/ast"
 	"lambda/ast/sexpr"
-	"lambda/ast/tree"
 	debruijn "lambda/middle/de-bruijn"
+	"lambda/middle/desugar"
 	"lambda/syntax/parser"
 	"lambda/util"
 	"strings"
@@ -44,42 +45,15 @@ func testEvalEquality(text, expected string) error {
 		return report_errors(&logger)
 	}
 
-	result := debruijn.ToDeBruijn(source_code, namedTree)
+	coreTree := desugar.Desugar(source_code, namedTree)
+	result := debruijn.ToDeBruijn(source_code, coreTree)
 	de_bruijn_tree := result.Tree
 
-	log_computation := func(t tree.Tree) {
-		tree := ast.Print(source_code, t, t.RootId())
-		pretty := sexpr.Spaced(tree)
-		logger.Add(util.NewMessage(util.Debug, 0, 0, "e", pretty))
+	eval_tree, err := Eval(context.Background(), de_bruijn_tree, de_bruijn_tree.RootId(), EvalOptions{})
+	if err != nil {
+		return err
 	}
-
-	eval_tree := Eval(log_computation, de_bruijn_tree, de_bruijn_tree.RootId())
 	got := ast.Print(source_code, eval_tree, eval_tree.RootId())
-	// for !logger.IsEmpty() {
-	// 	m, _ := logger.Next()
-	// 	fmt.Println(m)
-	// }
-
-	// interpret_variables := func(s string, var_names map[int]string) string {
-	// 	spaced_left := strings.ReplaceAll(s, ")", " ) ")
-	// 	spaced_right := strings.ReplaceAll(spaced_left, "(", " ( ")
-	// 	words := strings.Split(spaced_right, " ")
-	// 	for index, name := range var_names {
-	// 		for i := range words {
-	// 			word := words[i]
-	// 			result, err := strconv.Atoi(word)
-	// 			if err == nil {
-	// 				if index == result {
-	// 					words[i] = name
-	// 					break
-	// 				}
-	// 			}
-	// 		}
-	// 	}
-	// 	return strings.Join(words, " ")
-	// }
-	// printed := ast.Print(source_code, eval_tree, eval_tree.RootId())
-	// got := sexpr.Spaced(interpret_variables(printed, result.VariableNames))
 
 	if sexpr.Minified(got) != sexpr.Minified(expected) {
 		lhs := sexpr.Spaced(got)
@@ -191,13 +165,7 @@ func TestFactorial(test *testing.T) {
     let Fst = λp.(p True) in
     let Snd = λp.(p False) in
 
-    let 0 = False in
     let Succ = λn.λf.λx.(f ((n f) x)) in
-    let 1 = (Succ 0) in
-    let 2 = (Succ 1) in
-    let 3 = (Succ 2) in
-    let 4 = (Succ 3) in
-    let 5 = (Succ 4) in
 
     let Plus = λm.λn.λs.λz.((m s) ((n s) z)) in
     let Mult = λm.λn.λs.(m (n s)) in
@@ -210,7 +178,30 @@ func TestFactorial(test *testing.T) {
 	let FactRec = (Y Fact) in
         (FactRec 4)
     `
-	expected := `2`
+	expected := `(λ (λ (1 (1 (1 (1 (1 (1 (1 (1 (1 (1 (1 (1
+        (1 (1 (1 (1 (1 (1 (1 (1 (1 (1 (1 (1 0))))))))))))))))))))))))))`
+	if e := testEvalEquality(text, expected); e != nil {
+		test.Error(e)
+	}
+}
+
+func TestLetRec(test *testing.T) {
+	text := `
+    let True = λt.λf.t in
+    let False = λt.λf.f in
+    let If = λb.λx.λy.((b x) y) in
+    let IsZero = λn.((n (λx.False)) True) in
+    let Succ = λn.λf.λx.(f ((n f) x)) in
+    let Pred = λn.λf.λx.(((n (λg.λh.(h (g f)))) (λu.x)) (λu.u)) in
+    let Mult = λm.λn.λs.(m (n s)) in
+    let Three = (Succ (Succ (Succ False))) in
+
+    letrec Fact = λn.(((If (IsZero n)) (Succ False)) ((Mult n) (Fact (Pred n)))) in
+    letrec Even = λn.(((If (IsZero n)) True) (Odd (Pred n)))
+       and Odd = λn.(((If (IsZero n)) False) (Even (Pred n))) in
+        (((Odd Three) (Fact Three)) (Even Three))
+    `
+	expected := `(λ (λ (1 (1 (1 (1 (1 (1 0))))))))`
 	if e := testEvalEquality(text, expected); e != nil {
 		test.Error(e)
 	}
@@ -258,3 +249,33 @@ func TestFactorial(test *testing.T) {
 // 		test.Error(e)
 // 	}
 // }
+
+func TestEvalNamedReadback(test *testing.T) {
+	cases := [...]struct{ text, expected string }{
+		// free y is not captured by binder with the same name
+		{`((λx.λy.(x y)) y)`, `λy1.(y y1)`},
+		{`((λf.λx.(f (f x))) λg.λy.(g y))`, `λx.λy.(x y)`},
+		{`(λx.λx.x)`, `λx.λx1.x1`},
+		{`((λx.(x x)) (λy.(y z)))`, `(z z)`},
+	}
+	for _, c := range cases {
+		logger := util.NewLogger()
+		tokenizer := parser.NewTokenizer(&logger)
+		source_code := tokenizer.Tokenize("test", *utf8string.NewString(c.text))
+		parser := parser.NewParser(&logger)
+		named_tree := parser.Parse(source_code)
+		if m, ok := logger.Next(); ok {
+			test.Fatal(m)
+		}
+
+		result := debruijn.ToDeBruijn(source_code, desugar.Desugar(source_code, named_tree))
+		eval_tree, err := Eval(context.Background(), result.Tree, result.Tree.RootId(), EvalOptions{})
+		if err != nil {
+			test.Fatal(err)
+		}
+		named_source, readback := debruijn.FromDeBruijn(source_code, result.VariableNames, eval_tree)
+		if got := ast.PrintNamed(named_source, readback, readback.RootId()); got != c.expected {
+			test.Errorf("%s: expected %s got %s", c.text, c.expected, got)
+		}
+	}
+}
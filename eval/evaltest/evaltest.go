@@ -0,0 +1,108 @@
+// Package evaltest has fixtures that tests of evaluators share: parsing of terms, Church
+// numeral prelude and comparison of backend with Eval
+package evaltest
+
+import (
+	"context"
+	"lambda/ast/ast"
+	"lambda/ast/sexpr"
+	"lambda/ast/tree"
+	"lambda/eval"
+	debruijn "lambda/middle/de-bruijn"
+	"lambda/middle/desugar"
+	"lambda/middle/numeral"
+	"lambda/syntax/parser"
+	"lambda/syntax/source"
+	"lambda/util"
+	"testing"
+
+	"golang.org/x/exp/utf8string"
+)
+
+// Prelude defines booleans, arithmetic of Church numerals, fixpoint and factorial,
+// text of term goes right after it
+const Prelude = `
+    let True = λt f.t in
+    let False = λt f.f in
+    let IsZero = λn.(n (λx.False) True) in
+    let Pred = λn f x.(n (λg h.(h (g f))) (λu.x) (λu.u)) in
+    let Mult = λm n s.(m (n s)) in
+    let Y = λf.((λx.(f (x x))) (λx.(f (x x)))) in
+    let Fact = (Y λf n.(IsZero n 1 (Mult n (f (Pred n))))) in
+    let Two = λf x.(f (f x)) in
+    let Three = λf x.(f (f (f x))) in
+`
+
+// DeBruijn parses text in non-strict mode and translates it into de bruijn tree,
+// numerals are Church ones
+func DeBruijn(test testing.TB, text string) (source.SourceCode, tree.Tree) {
+	return DeBruijnWithNumerals(test, text, numeral.Church)
+}
+
+// DeBruijnWithNumerals is DeBruijn with given encoding of numerals
+func DeBruijnWithNumerals(test testing.TB, text string, encoding numeral.Encoding) (source.SourceCode, tree.Tree) {
+	test.Helper()
+	logger := util.NewLogger()
+	tokenizer := parser.NewTokenizer(&logger)
+	source_code := tokenizer.Tokenize("test", *utf8string.NewString(text))
+	parser := parser.NewParserWithMode(&logger, parser.ModeNonStrict)
+	named_tree := parser.Parse(source_code)
+	if m, ok := logger.Next(); ok {
+		test.Fatal(m)
+	}
+	result := debruijn.ToDeBruijn(source_code, desugar.DesugarWithNumerals(source_code, named_tree, encoding))
+	return source_code, result.Tree
+}
+
+// Backend has the signature of eval.Eval
+type Backend func(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options eval.EvalOptions) (tree.Tree, error)
+
+// Compare checks that backend gives the same term as eval.Eval for every text, both
+// are run with options and neither of them may hit a limit
+func Compare(test *testing.T, backend Backend, options eval.EvalOptions, texts ...string) {
+	test.Helper()
+	for _, text := range texts {
+		source_code, t := DeBruijn(test, text)
+		expected, err := eval.Eval(context.Background(), t, t.RootId(), options)
+		if err != nil {
+			test.Fatal(err)
+		}
+		got, err := backend(context.Background(), t, t.RootId(), options)
+		if err != nil {
+			test.Fatalf("%s: %v", text, err)
+		}
+		lhs := ast.Print(source_code, got, got.RootId())
+		rhs := ast.Print(source_code, expected, expected.RootId())
+		if sexpr.Minified(lhs) != sexpr.Minified(rhs) {
+			test.Errorf("%s: expected %s got %s", text, rhs, lhs)
+		}
+	}
+}
+
+// Counter is tracer that counts callbacks and keeps what OnDone got
+type Counter struct {
+	Redexes, Substitutions, Collections, Done int
+	Reductions                                int
+	Result                                    tree.Tree
+}
+
+func (c *Counter) OnRedex(step int, t tree.Tree, redex tree.NodeId) {
+	c.Redexes++
+	if step != c.Redexes {
+		panic("steps are not consecutive")
+	}
+}
+
+func (c *Counter) OnSubstitute(step int, occurrence, argument tree.NodeId) {
+	c.Substitutions++
+}
+
+func (c *Counter) OnGC(before, after int) {
+	c.Collections++
+}
+
+func (c *Counter) OnDone(reductions int, result tree.Tree) {
+	c.Done++
+	c.Reductions = reductions
+	c.Result = result
+}
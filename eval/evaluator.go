@@ -0,0 +1,106 @@
+package eval
+
+import (
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+)
+
+// how many reductions are done between garbage collections
+const gc_period = 10
+
+// Evaluator reduces term one redex at a time, Eval is a loop over it.
+// Tree returned by its methods shares nodes with evaluator and is valid until next Step,
+// clone it to keep
+type Evaluator struct {
+	t          tree.MutableTree
+	strategy   Strategy
+	tracer     Tracer
+	reductions int
+	// next redex, NodeInvalid if not found yet
+	redex tree.NodeId
+	// nil unless evaluator is made with NewEvaluatorWithHistory
+	history *history
+}
+
+// NewEvaluator prepares evaluation of term at root, limits of options are not applied
+func NewEvaluator(in_tree tree.Tree, root tree.NodeId, options EvalOptions) *Evaluator {
+	e := &Evaluator{
+		t:        tree.NewMutableTree(in_tree),
+		strategy: options.Strategy,
+		tracer:   options.Tracer,
+		redex:    tree.NodeInvalid,
+	}
+	if e.strategy == nil {
+		e.strategy = NormalOrder
+	}
+	if e.tracer == nil {
+		e.tracer = NopTracer{}
+	}
+	e.t.SetRoot(root)
+	return e
+}
+
+func (e *Evaluator) Tree() tree.Tree {
+	return e.t.Tree
+}
+
+func (e *Evaluator) Reductions() int {
+	return e.reductions
+}
+
+// Redex is the application that next Step contracts, NodeNull if evaluation is done
+func (e *Evaluator) Redex() tree.NodeId {
+	if e.redex == tree.NodeInvalid {
+		e.redex = e.strategy.NextRedex(e.t.Tree, e.t.RootId())
+	}
+	return e.redex
+}
+
+// Done reports that strategy has no redex to contract, term is in its normal form
+func (e *Evaluator) Done() bool {
+	return e.Redex() == tree.NodeNull
+}
+
+// Step performs exactly one β-reduction. It returns contracted redex, which node holds
+// the contractum in the new tree, or NodeNull and unchanged tree if evaluation is done
+func (e *Evaluator) Step() (tree.NodeId, tree.Tree) {
+	app_id := e.Redex()
+	if app_id == tree.NodeNull {
+		return app_id, e.t.Tree
+	}
+	// collect garbage only here, so trees and ids that accessors return stay valid until Step
+	if e.reductions > 0 && e.reductions%gc_period == 0 {
+		e.CollectGarbage()
+		app_id = e.Redex()
+	}
+	if e.history != nil {
+		e.history.record(e.reductions, e.t)
+	}
+	e.reductions++
+	step := e.reductions
+	e.tracer.OnRedex(step, e.t.Tree, app_id)
+
+	t := &e.t
+	app := ast.ToApplicationNode(t.Tree, t.Node(app_id))
+	lambda := ast.ToPureAbstractionNode(t.Tree, t.Node(app.Lhs()))
+	app_rhs := app.Rhs()
+	lambda_body := lambda.Body()
+
+	shift_indicies(t, app_rhs, 0, 1)
+	substitute(t, lambda_body, app_rhs, 0, func(occurrence tree.NodeId) {
+		e.tracer.OnSubstitute(step, occurrence, app_rhs)
+	})
+	shift_indicies(t, lambda_body, 0, -1)
+
+	t.SetNode(app_id, t.Node(lambda_body))
+	e.redex = tree.NodeInvalid
+	return app_id, e.t.Tree
+}
+
+// CollectGarbage drops nodes unreachable from root, node ids change
+func (e *Evaluator) CollectGarbage() {
+	before := e.t.Count()
+	collect_garbage(&e.t, e.t.RootId())
+	e.redex = tree.NodeInvalid
+	e.tracer.OnGC(before, e.t.Count())
+}
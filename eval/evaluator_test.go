@@ -0,0 +1,86 @@
+package eval_test
+
+import (
+	"context"
+	"lambda/ast/ast"
+	"lambda/ast/sexpr"
+	"lambda/ast/tree"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+)
+
+func TestEvaluatorStep(test *testing.T) {
+	source_code, t := evaltest.DeBruijn(test, `(λx.(x x)) ((λy.y) z)`)
+	e := eval.NewEvaluator(t, t.RootId(), eval.EvalOptions{})
+	expected := [...]string{
+		`(((λ 0) 0) ((λ 0) 0))`,
+		`(0 ((λ 0) 0))`,
+		`(0 0)`,
+	}
+	for i, step := range expected {
+		if e.Done() {
+			test.Fatalf("Done after %d steps", i)
+		}
+		redex, got := e.Step()
+		if e.Reductions() != i+1 {
+			test.Errorf("Expected %d reductions, got %d", i+1, e.Reductions())
+		}
+		printed := ast.Print(source_code, got, got.RootId())
+		if sexpr.Minified(printed) != sexpr.Minified(step) {
+			test.Errorf("Step %d: expected %s got %s", i+1, step, printed)
+		}
+		if redex < 0 || int(redex) >= got.Count() {
+			test.Errorf("Step %d: redex is not in new tree", i+1)
+		}
+	}
+	if !e.Done() {
+		test.Error("Expected normal form")
+	}
+	if redex, _ := e.Step(); redex != tree.NodeNull {
+		test.Error("Step after normal form contracted something")
+	}
+}
+
+func TestEvaluatorMatchesEval(test *testing.T) {
+	source_code, t := evaltest.DeBruijn(test, `
+        let Y = λf.((λx.(f (x x))) (λx.(f (x x)))) in
+        let Pred = λn f x.(n (λg h.(h (g f))) (λu.x) (λu.u)) in
+        let F = λf n.(n (λx.(f (Pred n))) z) in
+        (Y F 4)`)
+	e := eval.NewEvaluator(t, t.RootId(), eval.EvalOptions{})
+	for !e.Done() {
+		e.Step()
+	}
+	result, err := eval.Eval(context.Background(), t, t.RootId(), eval.EvalOptions{})
+	if err != nil {
+		test.Fatal(err)
+	}
+	stepped := ast.Print(source_code, e.Tree(), e.Tree().RootId())
+	evaluated := ast.Print(source_code, result, result.RootId())
+	if sexpr.Minified(stepped) != sexpr.Minified(evaluated) {
+		test.Errorf("Expected %s got %s", evaluated, stepped)
+	}
+}
+
+func TestEvaluatorAccessorsKeepTree(test *testing.T) {
+	// garbage is collected every few steps, Tree taken before Redex must still hold the redex
+	_, t := evaltest.DeBruijn(test, evaltest.Prelude+`(Fact 3)`)
+	e := eval.NewEvaluator(t, t.RootId(), eval.EvalOptions{})
+	for step := 0; step < 5*eval.GCPeriod; step++ {
+		t := e.Tree()
+		count := t.Count()
+		redex := e.Redex()
+		if redex == tree.NodeNull {
+			test.Fatalf("Normal form after %d steps", step)
+		}
+		if e.Tree().Count() != count {
+			test.Fatalf("Step %d: tree changed without Step", step)
+		}
+		app := t.Node(redex)
+		if app.Tag != tree.NodeApplication || t.Node(ast.ToApplicationNode(t, app).Lhs()).Tag != tree.NodePureAbstraction {
+			test.Fatalf("Step %d: redex %d is not a redex of tree", step, redex)
+		}
+		e.Step()
+	}
+}
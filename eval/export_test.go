@@ -0,0 +1,11 @@
+package eval
+
+// internals that tests of package eval_test look at
+
+const HistoryInterval = history_interval
+
+func Checkpoints(e *Evaluator) int {
+	return len(e.history.checkpoints)
+}
+
+const GCPeriod = gc_period
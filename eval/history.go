@@ -0,0 +1,111 @@
+package eval
+
+import (
+	"lambda/ast/tree"
+)
+
+// checkpoints are taken every this many reductions at first
+const history_interval = 16
+
+type checkpoint struct {
+	reductions int
+	tree       tree.Tree
+}
+
+// history keeps snapshots of evaluator tree, term at any earlier step is restored by replaying
+// reductions from the closest checkpoint before it. When there are too many checkpoints
+// every other one is dropped and interval doubles, so memory stays bounded by limit
+// snapshots while replay gets longer for long evaluations
+type history struct {
+	checkpoints []checkpoint
+	interval    int
+	limit       int
+}
+
+func (h *history) record(reductions int, t tree.MutableTree) {
+	if reductions%h.interval != 0 {
+		return
+	}
+	if last := h.checkpoints[len(h.checkpoints)-1]; last.reductions >= reductions {
+		return
+	}
+	snapshot := tree.NewMutableTree(t.Tree)
+	collect_garbage(&snapshot, snapshot.RootId())
+	h.checkpoints = append(h.checkpoints, checkpoint{reductions: reductions, tree: snapshot.Tree})
+
+	if len(h.checkpoints) > h.limit {
+		h.interval *= 2
+		kept := h.checkpoints[:0]
+		for _, c := range h.checkpoints {
+			if c.reductions%h.interval == 0 {
+				kept = append(kept, c)
+			}
+		}
+		h.checkpoints = kept
+	}
+}
+
+// closest returns the last checkpoint taken at or before given step
+func (h *history) closest(step int) checkpoint {
+	result := h.checkpoints[0]
+	for _, c := range h.checkpoints {
+		if c.reductions > step {
+			break
+		}
+		result = c
+	}
+	return result
+}
+
+// NewEvaluatorWithHistory is NewEvaluator that can go back to earlier steps, keeping
+// at most checkpoints snapshots of the term (at least 2)
+func NewEvaluatorWithHistory(in_tree tree.Tree, root tree.NodeId, options EvalOptions, checkpoints int) *Evaluator {
+	e := NewEvaluator(in_tree, root, options)
+	if checkpoints < 2 {
+		checkpoints = 2
+	}
+	initial := tree.NewMutableTree(e.t.Tree)
+	collect_garbage(&initial, initial.RootId())
+	e.history = &history{
+		checkpoints: []checkpoint{{reductions: 0, tree: initial.Tree}},
+		interval:    history_interval,
+		limit:       checkpoints,
+	}
+	return e
+}
+
+// Back undoes the last reduction, false if there is nothing to undo or evaluator has no history
+func (e *Evaluator) Back() bool {
+	if e.reductions == 0 {
+		return false
+	}
+	return e.Goto(e.reductions - 1)
+}
+
+// Goto moves evaluation to the term after given number of reductions. Going forward is
+// stepping, going back needs history. False if step is out of reach, evaluator stays
+// in normal form then if it is reached before step
+func (e *Evaluator) Goto(step int) bool {
+	if step < 0 {
+		return false
+	}
+	if step < e.reductions {
+		if e.history == nil {
+			return false
+		}
+		c := e.history.closest(step)
+		e.t = tree.NewMutableTree(c.tree)
+		e.reductions = c.reductions
+		e.redex = tree.NodeInvalid
+		// replay is not traced, observers have seen these reductions already
+		tracer := e.tracer
+		e.tracer = NopTracer{}
+		defer func() { e.tracer = tracer }()
+	}
+	for e.reductions < step {
+		if redex, _ := e.Step(); redex == tree.NodeNull {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package eval_test
+
+import (
+	"lambda/ast/ast"
+	"lambda/ast/sexpr"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+)
+
+func TestEvaluatorHistory(test *testing.T) {
+	source_code, t := evaltest.DeBruijn(test, `
+        let Y = λf.((λx.(f (x x))) (λx.(f (x x)))) in
+        let Pred = λn f x.(n (λg h.(h (g f))) (λu.x) (λu.u)) in
+        let F = λf n.(n (λx.(f (Pred n))) z) in
+        (Y F 3)`)
+	print := func(e *eval.Evaluator) string {
+		return sexpr.Minified(ast.Print(source_code, e.Tree(), e.Tree().RootId()))
+	}
+
+	// few checkpoints, so that they get thinned out
+	e := eval.NewEvaluatorWithHistory(t, t.RootId(), eval.EvalOptions{}, 3)
+	terms := []string{print(e)}
+	for !e.Done() {
+		e.Step()
+		terms = append(terms, print(e))
+	}
+	if len(terms) < 4*eval.HistoryInterval {
+		test.Fatalf("Evaluation is too short to test history: %d steps", len(terms))
+	}
+	if eval.Checkpoints(e) > 3 {
+		test.Errorf("Expected at most 3 checkpoints, got %d", eval.Checkpoints(e))
+	}
+
+	for _, step := range [...]int{len(terms) - 2, 1, 0, eval.HistoryInterval, len(terms) / 2, len(terms) - 1} {
+		if !e.Goto(step) {
+			test.Fatalf("Can't go to step %d", step)
+		}
+		if e.Reductions() != step || print(e) != terms[step] {
+			test.Errorf("Step %d: expected %s got %s", step, terms[step], print(e))
+		}
+	}
+	if !e.Back() || print(e) != terms[len(terms)-2] {
+		test.Error("Back doesn't undo the last reduction")
+	}
+	if e.Goto(len(terms)) {
+		test.Error("Went past normal form")
+	}
+	if e.Goto(0); e.Back() {
+		test.Error("Went back from the initial term")
+	}
+
+	e = eval.NewEvaluator(t, t.RootId(), eval.EvalOptions{})
+	e.Step()
+	if e.Back() {
+		test.Error("Went back without history")
+	}
+}
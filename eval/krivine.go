@@ -0,0 +1,164 @@
+package eval
+
+import (
+	"context"
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+)
+
+// term of input tree together with values of its bound variables, env holds as many
+// closures as there are abstractions around term in the input tree
+type closure struct {
+	term tree.NodeId
+	env  *environment
+}
+
+// persistent list, innermost binding first
+type environment struct {
+	value closure
+	next  *environment
+}
+
+func (e *environment) lookup(index int) (closure, bool) {
+	for ; e != nil; e = e.next {
+		if index == 0 {
+			return e.value, true
+		}
+		index--
+	}
+	return closure{}, false
+}
+
+func (e *environment) len() int {
+	n := 0
+	for ; e != nil; e = e.next {
+		n++
+	}
+	return n
+}
+
+// Krivine reduces term to weak head normal form by call by name, like Eval with WeakHeadNormal,
+// but on the Krivine abstract machine: arguments are not substituted, variables are looked up
+// in environment of closures instead. Result has the same form as the one of Eval, it is
+// read back from the machine by substituting closures into terms left unevaluated.
+// Strategy and Tracer callbacks except OnDone are ignored, MaxNodes bounds the argument stack
+func Krivine(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options EvalOptions) (tree.Tree, error) {
+	tracer := options.Tracer
+	if tracer == nil {
+		tracer = NopTracer{}
+	}
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	current := closure{term: root}
+	stack := make([]closure, 0)
+	reductions := 0
+	stop := func(limit *LimitError) (tree.Tree, error) {
+		partial := krivine_readback(in_tree, current, stack)
+		limit.Reductions, limit.Partial = reductions, partial
+		tracer.OnDone(reductions, partial)
+		return partial, limit
+	}
+	for transitions := 1; ; transitions++ {
+		if transitions%poll_period == 0 {
+			if err := ctx.Err(); err != nil {
+				return stop(LimitFromContext(err))
+			}
+		}
+
+		node := in_tree.Node(current.term)
+		switch node.Tag {
+		case tree.NodeApplication:
+			if options.MaxNodes > 0 && len(stack) >= options.MaxNodes {
+				return stop(&LimitError{Limit: LimitNodes})
+			}
+			app := ast.ToApplicationNode(in_tree, node)
+			stack = append(stack, closure{term: app.Rhs(), env: current.env})
+			current.term = app.Lhs()
+			continue
+		case tree.NodePureAbstraction:
+			if len(stack) == 0 {
+				break
+			}
+			if options.MaxReductions > 0 && reductions >= options.MaxReductions {
+				return stop(&LimitError{Limit: LimitReductions})
+			}
+			reductions++
+			argument := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			current = closure{
+				term: ast.ToPureAbstractionNode(in_tree, node).Body(),
+				env:  &environment{value: argument, next: current.env},
+			}
+			continue
+		case tree.NodeIndexVariable:
+			index := ast.ToIndexVariableNode(in_tree, node).Index()
+			if value, ok := current.env.lookup(index); ok {
+				current = value
+				continue
+			}
+			// free variable is the head
+		default:
+			panic("unreachable")
+		}
+		break
+	}
+
+	result := krivine_readback(in_tree, current, stack)
+	tracer.OnDone(reductions, result)
+	return result, nil
+}
+
+// krivine_readback builds term that machine state stands for: head closure applied to the
+// closures on the stack, with values of environments substituted for variables
+func krivine_readback(in_tree tree.Tree, head closure, stack []closure) tree.Tree {
+	nodes := make([]tree.Node, 0, in_tree.Count())
+	add_node := func(node tree.Node) tree.NodeId {
+		nodes = append(nodes, node)
+		return tree.NodeId(len(nodes) - 1)
+	}
+
+	// depth counts abstractions of term that are entered, out_depth counts all abstractions
+	// above the point of output
+	var aux func(c closure, depth, out_depth int) tree.NodeId
+	aux = func(c closure, depth, out_depth int) tree.NodeId {
+		node := in_tree.Node(c.term)
+		switch node.Tag {
+		case tree.NodeIndexVariable:
+			index := ast.ToIndexVariableNode(in_tree, node).Index()
+			if index < depth {
+				return add_node(node)
+			}
+			if value, ok := c.env.lookup(index - depth); ok {
+				return aux(value, 0, out_depth)
+			}
+			node.Lhs = tree.NodeId(index - depth - c.env.len() + out_depth)
+			return add_node(node)
+		case tree.NodePureAbstraction:
+			body := aux(closure{term: node.Lhs, env: c.env}, depth+1, out_depth+1)
+			node.Lhs = body
+			return add_node(node)
+		case tree.NodeApplication:
+			lhs := aux(closure{term: node.Lhs, env: c.env}, depth, out_depth)
+			rhs := aux(closure{term: node.Rhs, env: c.env}, depth, out_depth)
+			node.Lhs, node.Rhs = lhs, rhs
+			return add_node(node)
+		default:
+			panic("unreachable")
+		}
+	}
+
+	root := aux(head, 0, 0)
+	for i := len(stack) - 1; i >= 0; i-- {
+		argument := aux(stack[i], 0, 0)
+		root = add_node(tree.Node{
+			Tag:   tree.NodeApplication,
+			Token: in_tree.Node(stack[i].term).Token,
+			Lhs:   root,
+			Rhs:   argument})
+	}
+	return tree.NewTree(root, nodes)
+}
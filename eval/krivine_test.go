@@ -0,0 +1,50 @@
+package eval_test
+
+import (
+	"context"
+	"errors"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+)
+
+func TestKrivine(test *testing.T) {
+	texts := []string{
+		`x`,
+		`λx.((λy.y) x)`,
+		`(λx y.x) ((λz.z) a)`,
+		`(λx.(x x)) (λy.(y z))`,
+		`((λf x.(f (f x))) (λy.(y w)) v)`,
+		`(λx.λy.λz.(x z (y z))) (λa b.a) (λa b.b) c`,
+		// argument with free variables moved under abstractions
+		`(λx.λy.λz.(z x y)) (a b) (λw.(w c))`,
+		`(λa.(λb.λc.(c b a)) (a u)) (v w)`,
+		`
+        let Y = λf.((λx.(f (x x))) (λx.(f (x x)))) in
+        let Pred = λn f x.(n (λg h.(h (g f))) (λu.x) (λu.u)) in
+        let F = λf n.(n (λx.(f (Pred n))) z) in
+        (Y F (λs z.(s (s (s z)))))`,
+	}
+	evaltest.Compare(test, eval.Krivine, eval.EvalOptions{Strategy: eval.WeakHeadNormal}, texts...)
+}
+
+func TestKrivineLimits(test *testing.T) {
+	_, t := evaltest.DeBruijn(test, `(λx.(x x)) (λx.(x x))`)
+	_, err := eval.Krivine(context.Background(), t, t.RootId(), eval.EvalOptions{MaxReductions: 1000})
+	var limit *eval.LimitError
+	if !errors.As(err, &limit) || limit.Limit != eval.LimitReductions || limit.Reductions != 1000 {
+		test.Errorf("Expected reduction limit, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := eval.Krivine(ctx, t, t.RootId(), eval.EvalOptions{}); !errors.Is(err, context.Canceled) {
+		test.Errorf("Expected cancellation, got %v", err)
+	}
+
+	// stack grows with every reduction
+	_, t = evaltest.DeBruijn(test, `(λx.(x x x)) (λx.(x x x))`)
+	if _, err := eval.Krivine(context.Background(), t, t.RootId(), eval.EvalOptions{MaxNodes: 100}); !errors.As(err, &limit) || limit.Limit != eval.LimitNodes {
+		test.Errorf("Expected node limit, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package eval
+
+import (
+	"context"
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/syntax/source"
+)
+
+// semantic value: abstraction is Go function, neutral term is variable applied to arguments
+type semantic interface{}
+
+type semantic_function struct {
+	token source.TokenId
+	apply func(argument *semantic_thunk) semantic
+}
+
+type semantic_neutral struct {
+	token source.TokenId
+	// level of abstraction that binds the head, or free index if free is set
+	level int
+	free  bool
+	args  []*semantic_thunk
+}
+
+// semantic_thunk is lazy argument, value is computed on the first force and kept
+type semantic_thunk struct {
+	compute func() semantic
+	value   semantic
+}
+
+func (t *semantic_thunk) force() semantic {
+	if t.value == nil {
+		t.value = t.compute()
+		t.compute = nil
+	}
+	return t.value
+}
+
+type semantic_environment struct {
+	value *semantic_thunk
+	next  *semantic_environment
+}
+
+type nbe struct {
+	budget  *Budget
+	in_tree tree.Tree
+}
+
+// Normalize computes normal form by evaluation: term is interpreted into semantic values,
+// where abstractions are Go closures and β-reduction is Go function call, then the value
+// is read back (quoted) into de bruijn tree, applying functions to fresh variables.
+// Arguments are lazy and shared, so result is the one of Eval with NormalOrder whenever
+// normal order terminates, without substitution and shifting of indices.
+// It is a backend of EvalWhole, Strategy and MaxNodes are ignored
+func Normalize(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options EvalOptions) (tree.Tree, error) {
+	return EvalWhole(ctx, options, func(b *Budget) tree.Tree {
+		n := nbe{budget: b, in_tree: in_tree}
+		nodes := make([]tree.Node, 0, in_tree.Count())
+		quoted := n.quote(&nodes, n.eval(root, nil, 0), 0)
+		return tree.NewTree(quoted, nodes)
+	})
+}
+
+// eval interprets term in environment that holds length values, one for every abstraction around term
+func (n *nbe) eval(term tree.NodeId, env *semantic_environment, length int) semantic {
+	n.budget.Enter()
+	defer n.budget.Leave()
+	node := n.in_tree.Node(term)
+	switch node.Tag {
+	case tree.NodeIndexVariable:
+		index := ast.ToIndexVariableNode(n.in_tree, node).Index()
+		if index >= length {
+			return semantic_neutral{token: node.Token, level: index - length, free: true}
+		}
+		e := env
+		for i := 0; i < index; i++ {
+			e = e.next
+		}
+		return e.value.force()
+	case tree.NodePureAbstraction:
+		body := ast.ToPureAbstractionNode(n.in_tree, node).Body()
+		return semantic_function{
+			token: node.Token,
+			apply: func(argument *semantic_thunk) semantic {
+				return n.eval(body, &semantic_environment{value: argument, next: env}, length+1)
+			},
+		}
+	case tree.NodeApplication:
+		app := ast.ToApplicationNode(n.in_tree, node)
+		function := n.eval(app.Lhs(), env, length)
+		argument := &semantic_thunk{compute: func() semantic {
+			return n.eval(app.Rhs(), env, length)
+		}}
+		return n.apply(function, argument)
+	default:
+		panic("unreachable")
+	}
+}
+
+func (n *nbe) apply(function semantic, argument *semantic_thunk) semantic {
+	switch f := function.(type) {
+	case semantic_function:
+		n.budget.Reduce()
+		return f.apply(argument)
+	case semantic_neutral:
+		args := make([]*semantic_thunk, len(f.args), len(f.args)+1)
+		copy(args, f.args)
+		f.args = append(args, argument)
+		return f
+	default:
+		panic("unreachable")
+	}
+}
+
+// quote reads value back into tree, depth is the number of abstractions above it
+func (n *nbe) quote(nodes *[]tree.Node, value semantic, depth int) tree.NodeId {
+	n.budget.Enter()
+	defer n.budget.Leave()
+	add_node := func(node tree.Node) tree.NodeId {
+		*nodes = append(*nodes, node)
+		return tree.NodeId(len(*nodes) - 1)
+	}
+	switch v := value.(type) {
+	case semantic_function:
+		variable := &semantic_thunk{value: semantic_neutral{token: v.token, level: depth}}
+		body := n.quote(nodes, v.apply(variable), depth+1)
+		return add_node(tree.Node{
+			Tag:   tree.NodePureAbstraction,
+			Token: v.token,
+			Lhs:   body,
+			Rhs:   tree.NodeNull})
+	case semantic_neutral:
+		index := depth - 1 - v.level
+		if v.free {
+			index = v.level + depth
+		}
+		head := add_node(tree.Node{
+			Tag:   tree.NodeIndexVariable,
+			Token: v.token,
+			Lhs:   tree.NodeId(index),
+			Rhs:   tree.NodeNull})
+		for _, arg := range v.args {
+			argument := n.quote(nodes, arg.force(), depth)
+			head = add_node(tree.Node{
+				Tag:   tree.NodeApplication,
+				Token: source.TokenInvalid,
+				Lhs:   head,
+				Rhs:   argument})
+		}
+		return head
+	default:
+		panic("unreachable")
+	}
+}
@@ -0,0 +1,50 @@
+package eval_test
+
+import (
+	"context"
+	"errors"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+	"time"
+)
+
+func TestNormalize(test *testing.T) {
+	texts := []string{
+		`x`,
+		`λx.((λy.y) x)`,
+		`(λx y.x) ((λz.z) a)`,
+		`λx.λy.(x ((λz.(z y)) x))`,
+		`(λx.λy.λz.(z x y)) (a b) (λw.(w c))`,
+		`(λx y.y) ((λx.(x x)) (λx.(x x))) z`,
+		`λf.(f (λx.x) (f a))`,
+		evaltest.Prelude + `(Fact 3)`,
+		evaltest.Prelude + `(λx.(Mult x x)) (Fact 2)`,
+	}
+	evaltest.Compare(test, eval.Normalize, eval.EvalOptions{}, texts...)
+}
+
+func TestNormalizeLimits(test *testing.T) {
+	_, t := evaltest.DeBruijn(test, `(λx.(x x)) (λx.(x x))`)
+	_, err := eval.Normalize(context.Background(), t, t.RootId(), eval.EvalOptions{MaxReductions: 1000})
+	var limit *eval.LimitError
+	if !errors.As(err, &limit) || limit.Limit != eval.LimitReductions || limit.Reductions != 1000 {
+		test.Errorf("Expected reduction limit, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := eval.Normalize(ctx, t, t.RootId(), eval.EvalOptions{}); !errors.Is(err, context.Canceled) {
+		test.Errorf("Expected cancellation, got %v", err)
+	}
+}
+
+func TestNormalizeDepth(test *testing.T) {
+	// Go call of Ω's body nests in the one that applies it, so evaluation recurses without bound
+	_, t := evaltest.DeBruijn(test, `(λx.(x x)) (λx.(x x))`)
+	_, err := eval.Normalize(context.Background(), t, t.RootId(), eval.EvalOptions{Timeout: 3 * time.Second})
+	var limit *eval.LimitError
+	if !errors.As(err, &limit) || limit.Limit != eval.LimitDepth {
+		test.Errorf("Expected recursion depth limit, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"context"
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/syntax/source"
+)
+
+// value is weak head normal form: abstraction with its environment or
+// neutral term, variable that is applied to arguments
+type value struct {
+	// NodeNull for neutral term
+	abstraction tree.NodeId
+	env         *need_environment
+	// head of neutral term is level of abstraction that binds it, or free index if free is set
+	token source.TokenId
+	level int
+	free  bool
+	args  []*thunk
+}
+
+// thunk is argument that is evaluated once, when it is needed, and then updated with the value
+type thunk struct {
+	term  tree.NodeId
+	env   *need_environment
+	value *value
+}
+
+type need_environment struct {
+	value *thunk
+	next  *need_environment
+}
+
+func (e *need_environment) lookup(index int) (*thunk, bool) {
+	for ; e != nil; e = e.next {
+		if index == 0 {
+			return e.value, true
+		}
+		index--
+	}
+	return nil, false
+}
+
+type need_machine struct {
+	budget  *Budget
+	in_tree tree.Tree
+	thunks  int
+}
+
+// CallByNeed reduces term to normal form like Eval with NormalOrder, but arguments are shared:
+// each one becomes a thunk that is evaluated at most once and updated with its weak head
+// normal form. Normal form is read back from values, going under abstractions with fresh
+// variables. Work under abstraction is not shared, it is redone whenever abstraction is
+// applied or read back. It is a backend of EvalWhole, Strategy is ignored and MaxNodes bounds
+// the number of thunks
+func CallByNeed(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options EvalOptions) (tree.Tree, error) {
+	return EvalWhole(ctx, options, func(b *Budget) tree.Tree {
+		m := need_machine{budget: b, in_tree: in_tree}
+		nodes := make([]tree.Node, 0, in_tree.Count())
+		v := m.eval(root, nil)
+		result_root := m.readback(&nodes, v, 0)
+		return tree.NewTree(result_root, nodes)
+	})
+}
+
+func (m *need_machine) force(t *thunk) *value {
+	if t.value == nil {
+		t.value = m.eval(t.term, t.env)
+		// environment is not needed anymore
+		t.env = nil
+	}
+	return t.value
+}
+
+func (m *need_machine) delay(term tree.NodeId, env *need_environment) *thunk {
+	m.thunks++
+	m.budget.Nodes(m.thunks)
+	return &thunk{term: term, env: env}
+}
+
+func (m *need_machine) eval(term tree.NodeId, env *need_environment) *value {
+	m.budget.Enter()
+	defer m.budget.Leave()
+	node := m.in_tree.Node(term)
+	switch node.Tag {
+	case tree.NodeIndexVariable:
+		index := ast.ToIndexVariableNode(m.in_tree, node).Index()
+		if t, ok := env.lookup(index); ok {
+			return m.force(t)
+		}
+		length := 0
+		for e := env; e != nil; e = e.next {
+			length++
+		}
+		return &value{abstraction: tree.NodeNull, token: node.Token, level: index - length, free: true}
+	case tree.NodePureAbstraction:
+		return &value{abstraction: term, env: env, token: node.Token}
+	case tree.NodeApplication:
+		app := ast.ToApplicationNode(m.in_tree, node)
+		function := m.eval(app.Lhs(), env)
+		return m.apply(function, m.delay(app.Rhs(), env))
+	default:
+		panic("unreachable")
+	}
+}
+
+func (m *need_machine) apply(function *value, argument *thunk) *value {
+	if function.abstraction == tree.NodeNull {
+		args := make([]*thunk, len(function.args), len(function.args)+1)
+		copy(args, function.args)
+		neutral := *function
+		neutral.args = append(args, argument)
+		return &neutral
+	}
+	m.budget.Reduce()
+	body := ast.ToPureAbstractionNode(m.in_tree, m.in_tree.Node(function.abstraction)).Body()
+	return m.eval(body, &need_environment{value: argument, next: function.env})
+}
+
+// readback quotes value to normal form, depth is the number of abstractions above it
+func (m *need_machine) readback(nodes *[]tree.Node, v *value, depth int) tree.NodeId {
+	m.budget.Enter()
+	defer m.budget.Leave()
+	add_node := func(node tree.Node) tree.NodeId {
+		*nodes = append(*nodes, node)
+		return tree.NodeId(len(*nodes) - 1)
+	}
+	if v.abstraction != tree.NodeNull {
+		variable := &thunk{value: &value{abstraction: tree.NodeNull, token: v.token, level: depth}}
+		body := ast.ToPureAbstractionNode(m.in_tree, m.in_tree.Node(v.abstraction)).Body()
+		body_value := m.eval(body, &need_environment{value: variable, next: v.env})
+		return add_node(tree.Node{
+			Tag:   tree.NodePureAbstraction,
+			Token: v.token,
+			Lhs:   m.readback(nodes, body_value, depth+1),
+			Rhs:   tree.NodeNull})
+	}
+
+	index := depth - 1 - v.level
+	if v.free {
+		index = v.level + depth
+	}
+	head := add_node(tree.Node{
+		Tag:   tree.NodeIndexVariable,
+		Token: v.token,
+		Lhs:   tree.NodeId(index),
+		Rhs:   tree.NodeNull})
+	for _, arg := range v.args {
+		argument := m.readback(nodes, m.force(arg), depth)
+		head = add_node(tree.Node{
+			Tag:   tree.NodeApplication,
+			Token: m.in_tree.Node(arg.term).Token,
+			Lhs:   head,
+			Rhs:   argument})
+	}
+	return head
+}
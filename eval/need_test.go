@@ -0,0 +1,85 @@
+package eval_test
+
+import (
+	"context"
+	"errors"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+	"time"
+)
+
+func TestCallByNeed(test *testing.T) {
+	texts := []string{
+		`x`,
+		`λx.((λy.y) x)`,
+		`(λx y.x) ((λz.z) a)`,
+		`λx.λy.(x ((λz.(z y)) x))`,
+		`(λx.λy.λz.(z x y)) (a b) (λw.(w c))`,
+		// argument that doesn't terminate is never needed
+		`(λx y.y) ((λx.(x x)) (λx.(x x))) z`,
+		evaltest.Prelude + `(Fact 3)`,
+		evaltest.Prelude + `(λx.(Mult x x)) (Fact 2)`,
+	}
+	evaltest.Compare(test, eval.CallByNeed, eval.EvalOptions{}, texts...)
+}
+
+func TestCallByNeedSharing(test *testing.T) {
+	// Arg takes two reductions every time it is forced, x is needed twice,
+	// so every reduction besides the outer one and these of Arg is a sign of lost sharing
+	arg := `((λy.y) ((λy.y) w))`
+	_, t := evaltest.DeBruijn(test, `(λx.(x x)) `+arg)
+	need := evaltest.Counter{}
+	if _, err := eval.CallByNeed(context.Background(), t, t.RootId(), eval.EvalOptions{Tracer: &need}); err != nil {
+		test.Fatal(err)
+	}
+	// reductions of CallByNeed are reported to OnDone only
+	if need.Done != 1 {
+		test.Fatal("Tracer wasn't notified")
+	}
+	if need.Reductions != 1+2 {
+		test.Errorf("Expected Arg to be forced once, got %d reductions", need.Reductions)
+	}
+
+	normal := evaltest.Counter{}
+	if _, err := eval.Eval(context.Background(), t, t.RootId(), eval.EvalOptions{Tracer: &normal}); err != nil {
+		test.Fatal(err)
+	}
+	if normal.Redexes != 1+2*2 {
+		test.Errorf("Expected normal order to reduce Arg twice, got %d reductions", normal.Redexes)
+	}
+
+	_, t = evaltest.DeBruijn(test, evaltest.Prelude+`(λx.(Mult x x)) (Fact 3)`)
+	normal, need = evaltest.Counter{}, evaltest.Counter{}
+	if _, err := eval.Eval(context.Background(), t, t.RootId(), eval.EvalOptions{Tracer: &normal}); err != nil {
+		test.Fatal(err)
+	}
+	if _, err := eval.CallByNeed(context.Background(), t, t.RootId(), eval.EvalOptions{Tracer: &need}); err != nil {
+		test.Fatal(err)
+	}
+	if need.Reductions >= normal.Redexes {
+		test.Errorf("Expected less reductions than %d of normal order, got %d", normal.Redexes, need.Reductions)
+	}
+}
+
+func TestCallByNeedLimits(test *testing.T) {
+	_, t := evaltest.DeBruijn(test, `(λx.(x x)) (λx.(x x))`)
+	_, err := eval.CallByNeed(context.Background(), t, t.RootId(), eval.EvalOptions{MaxReductions: 1000})
+	var limit *eval.LimitError
+	if !errors.As(err, &limit) || limit.Limit != eval.LimitReductions || limit.Reductions != 1000 {
+		test.Errorf("Expected reduction limit, got %v", err)
+	}
+	if _, err := eval.CallByNeed(context.Background(), t, t.RootId(), eval.EvalOptions{MaxNodes: 1000}); !errors.As(err, &limit) || limit.Limit != eval.LimitNodes {
+		test.Errorf("Expected node limit, got %v", err)
+	}
+}
+
+func TestCallByNeedDepth(test *testing.T) {
+	// every reduction of Ω nests evaluation of its body, so the machine recurses without bound
+	_, t := evaltest.DeBruijn(test, `(λx.(x x)) (λx.(x x))`)
+	_, err := eval.CallByNeed(context.Background(), t, t.RootId(), eval.EvalOptions{Timeout: 3 * time.Second})
+	var limit *eval.LimitError
+	if !errors.As(err, &limit) || limit.Limit != eval.LimitDepth {
+		test.Errorf("Expected recursion depth limit, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package eval
+
+import (
+	"context"
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// contractum is redex contracted into nodes that were appended to the given ones
+type contractum struct {
+	nodes []tree.Node
+	root  tree.NodeId
+	// copies of argument that replaced occurrences of bound variable
+	occurrences []tree.NodeId
+}
+
+// contract builds contractum of redex after nodes, which are the ones of t or of its own,
+// then ids of it start from zero. t is only read, so redexes are contracted concurrently
+func contract(t tree.Tree, redex tree.NodeId, nodes []tree.Node) contractum {
+	app := ast.ToApplicationNode(t, t.Node(redex))
+	body := ast.ToPureAbstractionNode(t, t.Node(app.Lhs())).Body()
+	argument := app.Rhs()
+
+	c := contractum{nodes: nodes}
+	add_node := func(node tree.Node) tree.NodeId {
+		c.nodes = append(c.nodes, node)
+		return tree.NodeId(len(c.nodes) - 1)
+	}
+	// copy of id where free indices are shifted by amount
+	var shifted func(id tree.NodeId, cutoff, amount int) tree.NodeId
+	shifted = func(id tree.NodeId, cutoff, amount int) tree.NodeId {
+		node := t.Node(id)
+		switch node.Tag {
+		case tree.NodeIndexVariable:
+			index := ast.ToIndexVariableNode(t, node).Index()
+			if index >= cutoff {
+				index += amount
+			}
+			node.Lhs = tree.NodeId(index)
+		case tree.NodePureAbstraction:
+			node.Lhs = shifted(ast.ToPureAbstractionNode(t, node).Body(), cutoff+1, amount)
+		case tree.NodeApplication:
+			lhs, rhs := ast.ToApplicationNode(t, node).Children()
+			node.Lhs = shifted(lhs, cutoff, amount)
+			node.Rhs = shifted(rhs, cutoff, amount)
+		default:
+			panic("unreachable")
+		}
+		return add_node(node)
+	}
+	// copy of id under depth abstractions of the body with argument substituted
+	var aux func(id tree.NodeId, depth int) tree.NodeId
+	aux = func(id tree.NodeId, depth int) tree.NodeId {
+		node := t.Node(id)
+		switch node.Tag {
+		case tree.NodeIndexVariable:
+			index := ast.ToIndexVariableNode(t, node).Index()
+			if index == depth {
+				occurrence := shifted(argument, 0, depth)
+				c.occurrences = append(c.occurrences, occurrence)
+				return occurrence
+			}
+			if index > depth {
+				index--
+			}
+			node.Lhs = tree.NodeId(index)
+		case tree.NodePureAbstraction:
+			node.Lhs = aux(ast.ToPureAbstractionNode(t, node).Body(), depth+1)
+		case tree.NodeApplication:
+			lhs, rhs := ast.ToApplicationNode(t, node).Children()
+			node.Lhs = aux(lhs, depth)
+			node.Rhs = aux(rhs, depth)
+		default:
+			panic("unreachable")
+		}
+		return add_node(node)
+	}
+	c.root = aux(body, 0)
+	return c
+}
+
+// outermost_redexes are redexes that no other redex contains, from left to right
+func outermost_redexes(t tree.Tree, root tree.NodeId) []tree.NodeId {
+	redexes := make([]tree.NodeId, 0)
+	var aux func(tree.NodeId)
+	aux = func(id tree.NodeId) {
+		if is_redex(t, id) {
+			redexes = append(redexes, id)
+			return
+		}
+		node := t.Node(id)
+		switch node.Tag {
+		case tree.NodeIndexVariable:
+		case tree.NodePureAbstraction:
+			aux(ast.ToPureAbstractionNode(t, node).Body())
+		case tree.NodeApplication:
+			lhs, rhs := ast.ToApplicationNode(t, node).Children()
+			aux(lhs)
+			aux(rhs)
+		default:
+			panic("unreachable")
+		}
+	}
+	aux(root)
+	return redexes
+}
+
+// rounds with fewer redexes are contracted by the caller, as goroutines cost more than they save
+const parallel_round = 16
+
+// Parallel is ParallelWithWorkers with a worker for every processor
+func Parallel(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options EvalOptions) (tree.Tree, error) {
+	return ParallelWithWorkers(ctx, in_tree, root, runtime.GOMAXPROCS(0), options)
+}
+
+// ParallelWithWorkers reduces term to normal form by parallel outermost reduction: every
+// round contracts all redexes that are not inside other redexes. They are disjoint, so
+// workers contract them concurrently, and contracta are put into the tree in order, so
+// result and reduction count do not depend on scheduling. Parallel outermost reduction
+// reaches normal form whenever there is one, that is the same as Eval with NormalOrder
+// reaches. Strategy is ignored, Tracer is called from the caller's goroutine: OnRedex for
+// every redex of the round before it is contracted, then OnSubstitute, and OnGC when garbage
+// is collected, that is when the tree has doubled since the last collection. If MaxReductions
+// is hit in the middle of a round, only the leftmost redexes of it are contracted
+func ParallelWithWorkers(ctx context.Context, in_tree tree.Tree, root tree.NodeId, workers int, options EvalOptions) (tree.Tree, error) {
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+	tracer := options.Tracer
+	if tracer == nil {
+		tracer = NopTracer{}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	t := tree.NewMutableTree(in_tree)
+	t.SetRoot(root)
+	collect_garbage(&t, t.RootId())
+	live := t.Count()
+	reductions := 0
+	stop := func(limit *LimitError) (tree.Tree, error) {
+		limit.Reductions, limit.Partial = reductions, t.Tree
+		tracer.OnDone(reductions, t.Tree)
+		return t.Tree, limit
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return stop(LimitFromContext(err))
+		}
+		if t.Count() > 2*live || options.MaxNodes > 0 && t.Count() > options.MaxNodes {
+			before := t.Count()
+			collect_garbage(&t, t.RootId())
+			live = t.Count()
+			tracer.OnGC(before, live)
+		}
+		if options.MaxNodes > 0 && t.Count() > options.MaxNodes {
+			return stop(&LimitError{Limit: LimitNodes})
+		}
+
+		redexes := outermost_redexes(t.Tree, t.RootId())
+		if len(redexes) == 0 {
+			break
+		}
+		if options.MaxReductions > 0 {
+			left := options.MaxReductions - reductions
+			if left <= 0 {
+				return stop(&LimitError{Limit: LimitReductions})
+			}
+			if len(redexes) > left {
+				redexes = redexes[:left]
+			}
+		}
+		for i, redex := range redexes {
+			tracer.OnRedex(reductions+i+1, t.Tree, redex)
+		}
+
+		// small round is contracted right into the tree, nodes of the redexes are only read
+		in_place := workers == 1 || len(redexes) < parallel_round
+		contracta := make([]contractum, len(redexes))
+		if in_place {
+			for i, redex := range redexes {
+				contracta[i] = contract(t.Tree, redex, t.Nodes())
+				t.SetNodes(contracta[i].nodes)
+			}
+		} else {
+			next := int64(-1)
+			var wg sync.WaitGroup
+			for w := 0; w < workers && w < len(redexes); w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := int(atomic.AddInt64(&next, 1)); i < len(redexes); i = int(atomic.AddInt64(&next, 1)) {
+						contracta[i] = contract(t.Tree, redexes[i], nil)
+					}
+				}()
+			}
+			wg.Wait()
+		}
+
+		for i, redex := range redexes {
+			argument := ast.ToApplicationNode(t.Tree, t.Node(redex)).Rhs()
+			offset := tree.NodeId(0)
+			if !in_place {
+				nodes := t.Nodes()
+				offset = tree.NodeId(len(nodes))
+				for _, node := range contracta[i].nodes {
+					switch node.Tag {
+					case tree.NodePureAbstraction:
+						node.Lhs += offset
+					case tree.NodeApplication:
+						node.Lhs += offset
+						node.Rhs += offset
+					}
+					nodes = append(nodes, node)
+				}
+				t.SetNodes(nodes)
+			}
+			t.SetNode(redex, t.Node(offset+contracta[i].root))
+			reductions++
+			for _, occurrence := range contracta[i].occurrences {
+				tracer.OnSubstitute(reductions, offset+occurrence, argument)
+			}
+		}
+	}
+	collect_garbage(&t, t.RootId())
+	tracer.OnDone(reductions, t.Tree)
+	return t.Tree, nil
+}
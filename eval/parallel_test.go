@@ -0,0 +1,95 @@
+package eval_test
+
+import (
+	"context"
+	"errors"
+	"lambda/ast/tree"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParallel(test *testing.T) {
+	texts := []string{
+		`x`,
+		`λx.((λy.y) x)`,
+		`(λx y.x) ((λz.z) a)`,
+		`(λx.λy.λz.(z x y)) (a b) (λw.(w c))`,
+		`λf.(f ((λx.x) a) ((λx.x) b) ((λx.(x x)) (λy.y)))`,
+		// round that is large enough to be contracted by workers
+		`λf.(f` + strings.Repeat(` ((λx.(x x)) (λy.y))`, 32) + `)`,
+		// argument that doesn't terminate is erased before it is reduced for good
+		`(λx y.y) ((λx.(x x)) (λx.(x x))) z`,
+		evaltest.Prelude + `(Fact 3)`,
+		evaltest.Prelude + `(λx.(Mult x x)) (Fact 2)`,
+	}
+	// reduction counts of texts with every number of workers
+	counts := make([][]int, 0)
+	for _, workers := range [...]int{1, 2, 8} {
+		workers := workers
+		counts = append(counts, nil)
+		evaltest.Compare(test, func(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options eval.EvalOptions) (tree.Tree, error) {
+			tracer := evaltest.Counter{}
+			options.Tracer = &tracer
+			result, err := eval.ParallelWithWorkers(ctx, in_tree, root, workers, options)
+			if tracer.Redexes != tracer.Reductions {
+				test.Errorf("%d redexes traced for %d reductions", tracer.Redexes, tracer.Reductions)
+			}
+			counts[len(counts)-1] = append(counts[len(counts)-1], tracer.Reductions)
+			return result, err
+		}, eval.EvalOptions{}, texts...)
+	}
+	for i := range texts {
+		if counts[1][i] != counts[0][i] || counts[2][i] != counts[0][i] {
+			test.Errorf("%s: reductions depend on the number of workers: %d, %d and %d", texts[i], counts[0][i], counts[1][i], counts[2][i])
+		}
+	}
+}
+
+func TestParallelLimits(test *testing.T) {
+	omega := `(λx.(x x)) (λx.(x x))`
+	growing := `(λx.(x x x)) (λx.(x x x))`
+	cases := [...]struct {
+		name    string
+		text    string
+		options eval.EvalOptions
+		limit   eval.Limit
+	}{
+		{"reductions", omega, eval.EvalOptions{MaxReductions: 100}, eval.LimitReductions},
+		{"nodes", growing, eval.EvalOptions{MaxNodes: 1000}, eval.LimitNodes},
+		{"timeout", omega, eval.EvalOptions{Timeout: 10 * time.Millisecond}, eval.LimitTime},
+	}
+	for _, c := range cases {
+		test.Run(c.name, func(test *testing.T) {
+			_, t := evaltest.DeBruijn(test, c.text)
+			_, err := eval.Parallel(context.Background(), t, t.RootId(), c.options)
+			var limit *eval.LimitError
+			if !errors.As(err, &limit) {
+				test.Fatalf("Expected LimitError, got %v", err)
+			}
+			if limit.Limit != c.limit {
+				test.Errorf("Expected %s, got %s", c.limit, limit.Limit)
+			}
+			if limit.Partial.Count() == 0 {
+				test.Error("Expected partial term")
+			}
+		})
+	}
+
+	// rounds are cut short by reduction limit
+	_, t := evaltest.DeBruijn(test, `λf.(f (`+omega+`) (`+omega+`) (`+omega+`))`)
+	_, err := eval.Parallel(context.Background(), t, t.RootId(), eval.EvalOptions{MaxReductions: 5})
+	var limit *eval.LimitError
+	if !errors.As(err, &limit) || limit.Reductions != 5 {
+		test.Errorf("Expected stop after 5 reductions, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = eval.Parallel(ctx, t, t.RootId(), eval.EvalOptions{})
+	if !errors.Is(err, context.Canceled) {
+		test.Errorf("Expected cancellation, got %v", err)
+	}
+}
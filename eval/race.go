@@ -0,0 +1,94 @@
+package eval
+
+import (
+	"context"
+	"lambda/ast/tree"
+)
+
+// Contender is evaluator that takes part in Race, it has to reduce term to β-normal form.
+// It runs in the process of the others, so it has to stop with LimitError where it would
+// overflow Go stack, as the ones that use Budget do
+type Contender struct {
+	Name string
+	Eval func(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options EvalOptions) (tree.Tree, error)
+}
+
+func with_strategy(strategy Strategy) func(context.Context, tree.Tree, tree.NodeId, EvalOptions) (tree.Tree, error) {
+	return func(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options EvalOptions) (tree.Tree, error) {
+		options.Strategy = strategy
+		return Eval(ctx, in_tree, root, options)
+	}
+}
+
+var (
+	NormalOrderContender      = Contender{"normal", with_strategy(NormalOrder)}
+	CallByNeedContender       = Contender{"need", CallByNeed}
+	ApplicativeOrderContender = Contender{"applicative", with_strategy(ApplicativeOrder)}
+)
+
+// done_tracer keeps what OnDone got, so that only the winner is reported
+type done_tracer struct {
+	NopTracer
+	reductions int
+	result     tree.Tree
+}
+
+func (d *done_tracer) OnDone(reductions int, result tree.Tree) {
+	d.reductions = reductions
+	d.result = result
+}
+
+type race_result struct {
+	contender int
+	result    tree.Tree
+	err       error
+	tracer    *done_tracer
+}
+
+// Race runs contenders on clones of term in goroutines and returns normal form of the first
+// one that reaches it, with its name. The rest are canceled, Race returns when all of them
+// have stopped. Normal order, call by need and applicative order take part if contenders
+// are not given. Limits of options bound every contender on its own, Strategy is ignored
+// and Tracer gets only OnDone of the winner. If no contender reaches normal form, the
+// result and error of the first one are returned
+func Race(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options EvalOptions, contenders ...Contender) (tree.Tree, string, error) {
+	if len(contenders) == 0 {
+		contenders = []Contender{NormalOrderContender, CallByNeedContender, ApplicativeOrderContender}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan race_result, len(contenders))
+	for i, c := range contenders {
+		go func(i int, c Contender, t tree.Tree) {
+			tracer := &done_tracer{}
+			contender_options := options
+			contender_options.Tracer = tracer
+			result, err := c.Eval(ctx, t, root, contender_options)
+			results <- race_result{contender: i, result: result, err: err, tracer: tracer}
+		}(i, c, in_tree.Clone())
+	}
+
+	// every contender is waited for, so none of them outlives Race
+	var winner *race_result
+	failed := make([]race_result, len(contenders))
+	for range contenders {
+		r := <-results
+		if r.err != nil {
+			failed[r.contender] = r
+			continue
+		}
+		if winner == nil {
+			winner = &r
+			cancel()
+		}
+	}
+
+	if winner == nil {
+		return failed[0].result, contenders[0].Name, failed[0].err
+	}
+	if options.Tracer != nil {
+		options.Tracer.OnDone(winner.tracer.reductions, winner.result)
+	}
+	return winner.result, contenders[winner.contender].Name, nil
+}
@@ -0,0 +1,53 @@
+package eval_test
+
+import (
+	"context"
+	"errors"
+	"lambda/ast/tree"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+	"time"
+)
+
+func TestRace(test *testing.T) {
+	texts := []string{
+		`x`,
+		`λx.((λy.y) x)`,
+		`(λx.λy.λz.(z x y)) (a b) (λw.(w c))`,
+		evaltest.Prelude + `(λx.(Mult x x)) (Fact 2)`,
+		// applicative order never stops here
+		`(λx y.y) ((λx.(x x)) (λx.(x x))) z`,
+	}
+	evaltest.Compare(test, func(ctx context.Context, in_tree tree.Tree, root tree.NodeId, options eval.EvalOptions) (tree.Tree, error) {
+		tracer := evaltest.Counter{}
+		options.Tracer = &tracer
+		result, _, err := eval.Race(ctx, in_tree, root, options)
+		if tracer.Done != 1 || tracer.Redexes != 0 {
+			test.Error("Expected only OnDone of the winner")
+		}
+		return result, err
+	}, eval.EvalOptions{}, texts...)
+}
+
+func TestRaceFailure(test *testing.T) {
+	_, t := evaltest.DeBruijn(test, `(λx y.y) ((λx.(x x)) (λx.(x x))) z`)
+	_, winner, err := eval.Race(context.Background(), t, t.RootId(), eval.EvalOptions{MaxReductions: 100}, eval.ApplicativeOrderContender)
+	var limit *eval.LimitError
+	if !errors.As(err, &limit) || limit.Limit != eval.LimitReductions || winner != "applicative" {
+		test.Errorf("Expected reduction limit of applicative order, got %v from %s", err, winner)
+	}
+
+	_, t = evaltest.DeBruijn(test, `(λx.(x x)) (λx.(x x))`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := eval.Race(ctx, t, t.RootId(), eval.EvalOptions{}); !errors.Is(err, context.Canceled) {
+		test.Errorf("Expected cancellation, got %v", err)
+	}
+
+	// call by need stops at recursion depth limit, the rest run until timeout
+	_, winner, err = eval.Race(context.Background(), t, t.RootId(), eval.EvalOptions{Timeout: 200 * time.Millisecond})
+	if !errors.As(err, &limit) || limit.Limit != eval.LimitTime || winner != "normal" {
+		test.Errorf("Expected time limit of normal order, got %v from %s", err, winner)
+	}
+}
@@ -0,0 +1,184 @@
+package eval
+
+import (
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+)
+
+// Strategy picks redex to contract next. NodeNull means that term is in normal form
+// of the strategy, which is not necessarily β-normal form
+type Strategy interface {
+	NextRedex(t tree.Tree, root tree.NodeId) tree.NodeId
+}
+
+var (
+	// Leftmost outermost redex first, reaches β-normal form if there is one
+	NormalOrder Strategy = normal_order{}
+	// Call by name, stops at λ or at variable applied to arguments, arguments are left as is
+	WeakHeadNormal Strategy = weak_head_normal{}
+	// Call by name under leading abstractions, stops when head of the term is variable
+	HeadNormal Strategy = head_normal{}
+	// Leftmost innermost redex first, arguments are normalized before substitution
+	ApplicativeOrder Strategy = applicative_order{}
+	// Function and argument are reduced to values (abstractions) before substitution,
+	// nothing is reduced under λ
+	CallByValue Strategy = call_by_value{}
+)
+
+var strategy_names = [...]struct {
+	name     string
+	strategy Strategy
+}{
+	{"normal", NormalOrder},
+	{"whnf", WeakHeadNormal},
+	{"hnf", HeadNormal},
+	{"applicative", ApplicativeOrder},
+	{"cbv", CallByValue},
+}
+
+func StrategyByName(name string) (Strategy, bool) {
+	for _, s := range strategy_names {
+		if s.name == name {
+			return s.strategy, true
+		}
+	}
+	return nil, false
+}
+
+func StrategyNames() []string {
+	names := make([]string, 0, len(strategy_names))
+	for _, s := range strategy_names {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func is_redex(t tree.Tree, id tree.NodeId) bool {
+	n := t.Node(id)
+	return n.Tag == tree.NodeApplication &&
+		t.Node(ast.ToApplicationNode(t, n).Lhs()).Tag == tree.NodePureAbstraction
+}
+
+type normal_order struct{}
+
+func (normal_order) NextRedex(t tree.Tree, expr tree.NodeId) tree.NodeId {
+	var aux func(tree.NodeId) tree.NodeId
+	aux = func(id tree.NodeId) tree.NodeId {
+		n := t.Node(id)
+		switch n.Tag {
+		case tree.NodeApplication:
+			app := ast.ToApplicationNode(t, n)
+			lhs := t.Node(app.Lhs())
+			switch lhs.Tag {
+			case tree.NodeApplication:
+				if redex := aux(app.Lhs()); redex != tree.NodeNull {
+					return redex
+				}
+				return aux(app.Rhs())
+			case tree.NodePureAbstraction:
+				return id
+			case tree.NodeIndexVariable:
+				return aux(app.Rhs())
+			default:
+				panic("unreachable")
+			}
+		case tree.NodePureAbstraction:
+			return aux(ast.ToPureAbstractionNode(t, n).Body())
+		case tree.NodeIndexVariable:
+			return tree.NodeNull
+		default:
+			panic("unreachable")
+		}
+	}
+	return aux(expr)
+}
+
+type weak_head_normal struct{}
+
+func (weak_head_normal) NextRedex(t tree.Tree, expr tree.NodeId) tree.NodeId {
+	expr_node := t.Node(expr)
+	if expr_node.Tag == tree.NodeApplication {
+		v := ast.ToApplicationNode(t, expr_node)
+		cur := v.Lhs()
+		cur_node := t.Node(cur)
+		for cur_node.Tag == tree.NodeApplication {
+			cur_app := ast.ToApplicationNode(t, cur_node)
+
+			new_cur := cur_app.Lhs()
+			expr = cur
+			cur = new_cur
+			cur_node = t.Node(cur)
+		}
+		if cur_node.Tag == tree.NodePureAbstraction {
+			return expr
+		}
+	}
+	return tree.NodeNull
+}
+
+type head_normal struct{}
+
+func (head_normal) NextRedex(t tree.Tree, expr tree.NodeId) tree.NodeId {
+	for t.Node(expr).Tag == tree.NodePureAbstraction {
+		expr = ast.ToPureAbstractionNode(t, t.Node(expr)).Body()
+	}
+	return WeakHeadNormal.NextRedex(t, expr)
+}
+
+type applicative_order struct{}
+
+func (applicative_order) NextRedex(t tree.Tree, expr tree.NodeId) tree.NodeId {
+	var aux func(tree.NodeId) tree.NodeId
+	aux = func(id tree.NodeId) tree.NodeId {
+		n := t.Node(id)
+		switch n.Tag {
+		case tree.NodeApplication:
+			app := ast.ToApplicationNode(t, n)
+			if redex := aux(app.Lhs()); redex != tree.NodeNull {
+				return redex
+			}
+			if redex := aux(app.Rhs()); redex != tree.NodeNull {
+				return redex
+			}
+			if is_redex(t, id) {
+				return id
+			}
+			return tree.NodeNull
+		case tree.NodePureAbstraction:
+			return aux(ast.ToPureAbstractionNode(t, n).Body())
+		case tree.NodeIndexVariable:
+			return tree.NodeNull
+		default:
+			panic("unreachable")
+		}
+	}
+	return aux(expr)
+}
+
+type call_by_value struct{}
+
+func (call_by_value) NextRedex(t tree.Tree, expr tree.NodeId) tree.NodeId {
+	var aux func(tree.NodeId) tree.NodeId
+	aux = func(id tree.NodeId) tree.NodeId {
+		n := t.Node(id)
+		switch n.Tag {
+		case tree.NodeApplication:
+			app := ast.ToApplicationNode(t, n)
+			if redex := aux(app.Lhs()); redex != tree.NodeNull {
+				return redex
+			}
+			if redex := aux(app.Rhs()); redex != tree.NodeNull {
+				return redex
+			}
+			if is_redex(t, id) {
+				return id
+			}
+			return tree.NodeNull
+		case tree.NodePureAbstraction, tree.NodeIndexVariable:
+			return tree.NodeNull
+		default:
+			panic("unreachable")
+		}
+	}
+	return aux(expr)
+}
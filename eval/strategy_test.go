@@ -0,0 +1,136 @@
+package eval_test
+
+import (
+	"context"
+	"errors"
+	"lambda/ast/ast"
+	"lambda/ast/sexpr"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+	"time"
+)
+
+func TestStrategies(test *testing.T) {
+	cases := [...]struct {
+		text                                     string
+		normal, whnf, hnf, applicative, by_value string
+	}{
+		{
+			text:        `(λx y.x) ((λz.z) a)`,
+			normal:      `(λ 1)`,
+			whnf:        `(λ((λ 0) 1))`,
+			hnf:         `(λ 1)`,
+			applicative: `(λ 1)`,
+			by_value:    `(λ 1)`,
+		},
+		{
+			text:        `λx.x ((λy.y) z)`,
+			normal:      `(λ(0 1))`,
+			whnf:        `(λ(0((λ 0) 1)))`,
+			hnf:         `(λ(0((λ 0) 1)))`,
+			applicative: `(λ(0 1))`,
+			by_value:    `(λ(0((λ 0) 1)))`,
+		},
+		{
+			text:        `x ((λy.y) z)`,
+			normal:      `(0 1)`,
+			whnf:        `(0((λ 0) 1))`,
+			hnf:         `(0((λ 0) 1))`,
+			applicative: `(0 1)`,
+			by_value:    `(0 1)`,
+		},
+	}
+	for _, c := range cases {
+		source_code, t := evaltest.DeBruijn(test, c.text)
+		expected := map[eval.Strategy]string{
+			eval.NormalOrder:      c.normal,
+			eval.WeakHeadNormal:   c.whnf,
+			eval.HeadNormal:       c.hnf,
+			eval.ApplicativeOrder: c.applicative,
+			eval.CallByValue:      c.by_value,
+		}
+		for _, name := range eval.StrategyNames() {
+			strategy, _ := eval.StrategyByName(name)
+			result, err := eval.Eval(context.Background(), t, t.RootId(), eval.EvalOptions{Strategy: strategy})
+			if err != nil {
+				test.Fatal(err)
+			}
+			got := ast.Print(source_code, result, result.RootId())
+			if sexpr.Minified(got) != sexpr.Minified(expected[strategy]) {
+				test.Errorf("%s with %s: expected %s got %s", c.text, name, expected[strategy], got)
+			}
+		}
+	}
+}
+
+func TestStrategyRedexChoice(test *testing.T) {
+	// argument diverges, so only strategies that don't evaluate it first terminate
+	text := `(λx.y) ((λx.x x) (λx.x x))`
+	source_code, t := evaltest.DeBruijn(test, text)
+	cases := map[eval.Strategy]string{
+		eval.NormalOrder:      `((λ 1)((λ(0 0))(λ(0 0))))`,
+		eval.WeakHeadNormal:   `((λ 1)((λ(0 0))(λ(0 0))))`,
+		eval.HeadNormal:       `((λ 1)((λ(0 0))(λ(0 0))))`,
+		eval.ApplicativeOrder: `((λ(0 0))(λ(0 0)))`,
+		eval.CallByValue:      `((λ(0 0))(λ(0 0)))`,
+	}
+	for strategy, expected := range cases {
+		redex := strategy.NextRedex(t, t.RootId())
+		if got := ast.Print(source_code, t, redex); sexpr.Minified(got) != sexpr.Minified(expected) {
+			test.Errorf("Expected redex %s got %s", expected, got)
+		}
+	}
+}
+
+func TestEvalLimits(test *testing.T) {
+	omega := `(λx.(x x)) (λx.(x x))`
+	// grows with every reduction
+	growing := `(λx.(x x x)) (λx.(x x x))`
+	cases := [...]struct {
+		name    string
+		text    string
+		options eval.EvalOptions
+		limit   eval.Limit
+	}{
+		{"reductions", omega, eval.EvalOptions{MaxReductions: 100}, eval.LimitReductions},
+		{"nodes", growing, eval.EvalOptions{MaxNodes: 1000}, eval.LimitNodes},
+		{"timeout", omega, eval.EvalOptions{Timeout: 10 * time.Millisecond}, eval.LimitTime},
+	}
+	for _, c := range cases {
+		test.Run(c.name, func(test *testing.T) {
+			_, t := evaltest.DeBruijn(test, c.text)
+			_, err := eval.Eval(context.Background(), t, t.RootId(), c.options)
+			var limit *eval.LimitError
+			if !errors.As(err, &limit) {
+				test.Fatalf("Expected LimitError, got %v", err)
+			}
+			if limit.Limit != c.limit {
+				test.Errorf("Expected %s, got %s", c.limit, limit.Limit)
+			}
+			if limit.Partial.Count() == 0 {
+				test.Error("Expected partial term")
+			}
+		})
+	}
+
+	_, t := evaltest.DeBruijn(test, omega)
+	_, err := eval.Eval(context.Background(), t, t.RootId(), eval.EvalOptions{MaxReductions: 3})
+	var limit *eval.LimitError
+	if !errors.As(err, &limit) || limit.Reductions != 3 {
+		test.Errorf("Expected stop after 3 reductions, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = eval.Eval(ctx, t, t.RootId(), eval.EvalOptions{})
+	if !errors.Is(err, context.Canceled) {
+		test.Errorf("Expected cancellation, got %v", err)
+	}
+
+	// limits don't matter when term has normal form in time
+	_, t = evaltest.DeBruijn(test, `(λx.x) y`)
+	if _, err := eval.Eval(context.Background(), t, t.RootId(), eval.EvalOptions{MaxReductions: 1}); err != nil {
+		test.Error(err)
+	}
+}
@@ -0,0 +1,34 @@
+package eval_test
+
+import (
+	"context"
+	"lambda/eval"
+	"lambda/eval/evaltest"
+	"testing"
+)
+
+func TestTracer(test *testing.T) {
+	// 3 reductions: x occurs twice, then y is substituted once in each of the others
+	text := `((λx.(x x)) (λy.y)) z`
+	_, t := evaltest.DeBruijn(test, text)
+	tracer := evaltest.Counter{}
+	got, err := eval.Eval(context.Background(), t, t.RootId(), eval.EvalOptions{Tracer: &tracer})
+	if err != nil {
+		test.Fatal(err)
+	}
+	if tracer.Redexes != 3 || tracer.Substitutions != 4 || tracer.Done != 1 {
+		test.Errorf("Unexpected trace: %d redexes, %d substitutions, %d done",
+			tracer.Redexes, tracer.Substitutions, tracer.Done)
+	}
+	if tracer.Result.Count() != got.Count() {
+		test.Error("OnDone got tree other than result")
+	}
+
+	_, t = evaltest.DeBruijn(test, `(λx.(x x)) (λx.(x x))`)
+	tracer = evaltest.Counter{}
+	eval.Eval(context.Background(), t, t.RootId(), eval.EvalOptions{Tracer: &tracer, MaxReductions: 25})
+	if tracer.Redexes != 25 || tracer.Collections == 0 || tracer.Done != 1 {
+		test.Errorf("Unexpected trace: %d redexes, %d collections, %d done",
+			tracer.Redexes, tracer.Collections, tracer.Done)
+	}
+}
@@ -8,10 +8,14 @@ import (
 )
 
 type DeBruijnResult struct {
-	Tree          tree.Tree
+	Tree tree.Tree
+	// names of free variables by their number, that is index of variable
+	// minus count of abstractions it is under
 	VariableNames map[int]string
 }
 
+// ToDeBruijn replaces names with indices. Abstraction keeps token of its bound variable,
+// so names can be restored later
 func ToDeBruijn(source_code source.SourceCode, tree_with_names tree.Tree) DeBruijnResult {
 	abstraction_vars := util.NewStack[string]()
 	free_vars_context := make(map[string]int)
@@ -38,6 +42,7 @@ func ToDeBruijn(source_code source.SourceCode, tree_with_names tree.Tree) DeBrui
 		if !ok {
 			free_id = len(free_vars_context)
 			free_vars_context[variable] = free_id
+			variable_names[free_id] = variable
 		} else {
 			free_id = index
 		}
@@ -62,7 +67,6 @@ func ToDeBruijn(source_code source.SourceCode, tree_with_names tree.Tree) DeBrui
 			if index == tree.NodeNull {
 				index = free_var_id(id)
 			}
-			variable_names[int(index)] = id
 			indicies.Push(index)
 		case tree.NodeApplication:
 			break
@@ -71,6 +75,11 @@ func ToDeBruijn(source_code source.SourceCode, tree_with_names tree.Tree) DeBrui
 			bound := t.Node(typed_node.Bound())
 			bound_node := ast.ToNamedVariableNode(source_code, t, bound)
 			abstraction_vars.Push(bound_node.Name)
+		case tree.NodeIndexVariable:
+			// closed subterms inserted by desugaring are already in de bruijn form
+			indicies.Push(tree.NodeId(ast.ToIndexVariableNode(t, node).Index()))
+		case tree.NodePureAbstraction:
+			break
 		default:
 			panic("Unreachable")
 		}
@@ -80,7 +89,7 @@ func ToDeBruijn(source_code source.SourceCode, tree_with_names tree.Tree) DeBrui
 		node := t.Node(node_id)
 		token := node.Token
 		switch node.Tag {
-		case tree.NodeNamedVariable:
+		case tree.NodeNamedVariable, tree.NodeIndexVariable:
 			index := indicies.ForcePop()
 			id := add_node(tree.Node{
 				Tag:   tree.NodeIndexVariable,
@@ -102,11 +111,19 @@ func ToDeBruijn(source_code source.SourceCode, tree_with_names tree.Tree) DeBrui
 			_ = node_ids.ForcePop() // variable (don't need named variable anymore)
 			id := add_node(tree.Node{
 				Tag:   tree.NodePureAbstraction,
-				Token: token,
+				Token: t.Node(ast.ToAbstractionNode(t, node).Bound()).Token,
 				Lhs:   body,
 				Rhs:   tree.NodeNull})
 			node_ids.Push(id)
 			abstraction_vars.Pop()
+		case tree.NodePureAbstraction:
+			body := node_ids.ForcePop()
+			id := add_node(tree.Node{
+				Tag:   tree.NodePureAbstraction,
+				Token: token,
+				Lhs:   body,
+				Rhs:   tree.NodeNull})
+			node_ids.Push(id)
 		default:
 			panic("Unreachable")
 		}
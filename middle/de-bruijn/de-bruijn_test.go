@@ -6,6 +6,7 @@ import (
 	"lambda/ast/ast"
 	"lambda/ast/sexpr"
 	"lambda/ast/tree"
+	"lambda/middle/desugar"
 	"lambda/syntax/parser"
 	"lambda/util"
 	"strings"
@@ -43,7 +44,8 @@ func testAstEquality(text, expected string) error {
 		return report_errors(&logger)
 	}
 
-	result := ToDeBruijn(source_code, namedTree)
+	coreTree := desugar.Desugar(source_code, namedTree)
+	result := ToDeBruijn(source_code, coreTree)
 	de_bruijn_tree := result.Tree
 
 	// test invariants
@@ -196,14 +198,36 @@ func TestAstMultipleLet(test *testing.T) {
         let c = 42 in
         ((* c) ((+ a) b))
     `
+	// numeric literals are Church numerals
 	expected := `
-        ((λ ((λ ((λ ((3 0)((4 2) 1))) 4)) 4)) 4)
+        ((λ ((λ ((λ ((3 0)((4 2) 1))) ` + church(42) + `)) ` + church(69) + `)) 2)
     `
 	if e := testAstEquality(text, expected); e != nil {
 		test.Error(e)
 	}
 }
 
+// church is de bruijn form of Church numeral n
+func church(n int) string {
+	return "(λ (λ " + strings.Repeat("(1 ", n) + "0" + strings.Repeat(")", n) + "))"
+}
+
+func TestAstNumerals(test *testing.T) {
+	cases := [...]struct{ text, expected string }{
+		{`0`, church(0)},
+		{`3`, church(3)},
+		{`(f 2)`, `(0 ` + church(2) + `)`},
+		{`λn.((n f) 1)`, `(λ ((0 1) ` + church(1) + `))`},
+		// sign makes an identifier
+		{`-1`, `0`},
+	}
+	for _, c := range cases {
+		if e := testAstEquality(c.text, c.expected); e != nil {
+			test.Errorf("%s: %v", c.text, e)
+		}
+	}
+}
+
 func TestAstUnclosedParen(test *testing.T) {
 	text := ` (λx.x `
 	expected := `λ 0`
@@ -219,3 +243,58 @@ func TestAstApplicationWithoutParens(test *testing.T) {
 		test.Error("Expected error with unparentesized application")
 	}
 }
+
+func TestAstLetScoping(test *testing.T) {
+	// bound variable is visible in the body only, value refers to outer x
+	text := `
+        λx.let x = (x x) in (x y)
+    `
+	expected := `
+        (λ ((λ (0 2)) (0 0)))
+    `
+	if e := testAstEquality(text, expected); e != nil {
+		test.Error(e)
+	}
+}
+
+func TestAstLetShadowing(test *testing.T) {
+	text := `
+        let x = a in let x = (x b) in x
+    `
+	expected := `
+        ((λ ((λ 0) (0 1))) 1)
+    `
+	if e := testAstEquality(text, expected); e != nil {
+		test.Error(e)
+	}
+}
+
+func TestFromDeBruijn(test *testing.T) {
+	cases := [...]struct{ text, expected string }{
+		{`λx.λy.λz.((x z) (y z))`, `λx.λy.λz.((x z) (y z))`},
+		{`(f λy.y)`, `(f λy.y)`},
+		{`((λx.x) λy.y)`, `((λx.x) λy.y)`},
+		{`λx.λx.(x y)`, `λx.λx1.(x1 y)`},
+		// binder doesn't take name of free variable
+		{`λy.(x λx.(x y))`, `λy.(x λx1.(x1 y))`},
+		{`let u = y in λv.(u x)`, `((λu.λv.(u x)) y)`},
+		// combinators inserted by desugaring have no names
+		{`letrec f = λn.(f n) in f`, `((λf.f) ((λx.((λx1.(x (x1 x1))) λx1.(x (x1 x1)))) λf.λn.(f n)))`},
+	}
+	for _, c := range cases {
+		logger := util.NewLogger()
+		tokenizer := parser.NewTokenizer(&logger)
+		source_code := tokenizer.Tokenize("test", *utf8string.NewString(c.text))
+		parser := parser.NewParser(&logger)
+		named_tree := parser.Parse(source_code)
+		if m, ok := logger.Next(); ok {
+			test.Fatal(m)
+		}
+
+		result := ToDeBruijn(source_code, desugar.Desugar(source_code, named_tree))
+		named_source, readback := FromDeBruijn(source_code, result.VariableNames, result.Tree)
+		if got := ast.PrintNamed(named_source, readback, readback.RootId()); got != c.expected {
+			test.Errorf("%s: expected %s got %s", c.text, c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package debruijn
+
+import (
+	"fmt"
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/syntax/source"
+	"strings"
+)
+
+// name given to binders that have no name of their own, like ones of desugared combinators
+const default_name = "x"
+
+// FromDeBruijn restores names of tree in de bruijn form. Abstraction is named after its bound
+// variable, which token it keeps, and free variables get their names from variable_names
+// (see DeBruijnResult). Name that is taken by enclosing binder or free variable gets numeric
+// suffix, so every variable refers to the same binding as its index does.
+// Returned source code is the given one with fresh names appended, tree refers to it
+func FromDeBruijn(source_code source.SourceCode, variable_names map[int]string, in_tree tree.Tree) (source.SourceCode, tree.Tree) {
+	named_source, named_tree, _ := FromDeBruijnWithMapping(source_code, variable_names, in_tree)
+	return named_source, named_tree
+}
+
+// FromDeBruijnWithMapping is FromDeBruijn that also maps ids of nodes reachable from root
+// of given tree to ids of their counterparts in the named tree
+func FromDeBruijnWithMapping(source_code source.SourceCode, variable_names map[int]string, in_tree tree.Tree) (source.SourceCode, tree.Tree, map[tree.NodeId]tree.NodeId) {
+	builder := source.NewBuilder(source_code.Filename())
+	builder.Append(source_code)
+	tokens := make(map[string]source.TokenId)
+	// token of given name, original one is reused if it has the same lexeme
+	token_of := func(name string, original source.TokenId) source.TokenId {
+		if original >= 0 && int(original) < source_code.TokenCount() &&
+			source_code.Token(original).Tag == source.TokenIdentifier &&
+			source_code.Lexeme(original) == name {
+			return original
+		}
+		if id, ok := tokens[name]; ok {
+			return id
+		}
+		line, col := -1, -1
+		if original >= 0 && int(original) < source_code.TokenCount() {
+			line, col = source_code.Location(original)
+		}
+		tokens[name] = builder.AddToken(source.TokenIdentifier, name, line, col)
+		return tokens[name]
+	}
+	name_of := func(token source.TokenId) string {
+		if token < 0 || int(token) >= source_code.TokenCount() ||
+			source_code.Token(token).Tag != source.TokenIdentifier {
+			return default_name
+		}
+		name := source_code.Lexeme(token)
+		if source.IsKeyword(name) || strings.ContainsRune(name, source.TokenDotRune) {
+			return default_name
+		}
+		return name
+	}
+
+	taken := make(map[string]int)
+	fresh := func(name string) string {
+		if taken[name] == 0 {
+			return name
+		}
+		base := strings.TrimRight(name, "0123456789")
+		if base == "" {
+			base = default_name
+		}
+		for i := 1; ; i++ {
+			if candidate := fmt.Sprintf("%s%d", base, i); taken[candidate] == 0 {
+				return candidate
+			}
+		}
+	}
+
+	// free variables are named first, so that binders don't capture them
+	free := make(map[int]string)
+	var collect func(id tree.NodeId, depth int)
+	collect = func(id tree.NodeId, depth int) {
+		node := in_tree.Node(id)
+		switch node.Tag {
+		case tree.NodeIndexVariable:
+			index := ast.ToIndexVariableNode(in_tree, node).Index()
+			if index < depth {
+				return
+			}
+			if _, ok := free[index-depth]; ok {
+				return
+			}
+			name, ok := variable_names[index-depth]
+			if !ok {
+				name = name_of(node.Token)
+			}
+			name = fresh(name)
+			taken[name]++
+			free[index-depth] = name
+		case tree.NodeApplication:
+			collect(node.Lhs, depth)
+			collect(node.Rhs, depth)
+		case tree.NodePureAbstraction:
+			collect(ast.ToPureAbstractionNode(in_tree, node).Body(), depth+1)
+		default:
+			panic("Unreachable")
+		}
+	}
+	collect(in_tree.RootId(), 0)
+
+	nodes := make([]tree.Node, 0, in_tree.Count())
+	add_node := func(node tree.Node) tree.NodeId {
+		nodes = append(nodes, node)
+		return tree.NodeId(len(nodes) - 1)
+	}
+	scope := make([]source.TokenId, 0)
+	mapping := make(map[tree.NodeId]tree.NodeId)
+	var aux func(id tree.NodeId) tree.NodeId
+	aux = func(id tree.NodeId) (named tree.NodeId) {
+		defer func() { mapping[id] = named }()
+		node := in_tree.Node(id)
+		switch node.Tag {
+		case tree.NodeIndexVariable:
+			index := ast.ToIndexVariableNode(in_tree, node).Index()
+			token := source.TokenInvalid
+			if index < len(scope) {
+				token = scope[len(scope)-1-index]
+			} else {
+				token = token_of(free[index-len(scope)], node.Token)
+			}
+			return add_node(tree.Node{
+				Tag:   tree.NodeNamedVariable,
+				Token: token,
+				Lhs:   tree.NodeNull,
+				Rhs:   tree.NodeNull})
+		case tree.NodeApplication:
+			lhs := aux(node.Lhs)
+			rhs := aux(node.Rhs)
+			return add_node(tree.Node{
+				Tag:   tree.NodeApplication,
+				Token: node.Token,
+				Lhs:   lhs,
+				Rhs:   rhs})
+		case tree.NodePureAbstraction:
+			name := fresh(name_of(node.Token))
+			token := token_of(name, node.Token)
+			bound := add_node(tree.Node{
+				Tag:   tree.NodeNamedVariable,
+				Token: token,
+				Lhs:   tree.NodeNull,
+				Rhs:   tree.NodeNull})
+			taken[name]++
+			scope = append(scope, token)
+			body := aux(ast.ToPureAbstractionNode(in_tree, node).Body())
+			scope = scope[:len(scope)-1]
+			taken[name]--
+			return add_node(tree.Node{
+				Tag:   tree.NodeAbstraction,
+				Token: token,
+				Lhs:   bound,
+				Rhs:   body})
+		default:
+			panic("Unreachable")
+		}
+	}
+	root := aux(in_tree.RootId())
+
+	return builder.SourceCode(), tree.NewTree(root, nodes), mapping
+}
@@ -0,0 +1,186 @@
+// Desugaring rewrites named tree produced by parser into core form, where only
+// variables, abstractions and applications are left. Should run before de bruijn conversion
+package desugar
+
+import (
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/middle/numeral"
+	"lambda/syntax/source"
+)
+
+// Desugar returns new tree without syntactic sugar:
+//
+//	let x = v in e => ((λx.e) v)
+//
+// Hence x is bound in e only and free occurrences of x in v refer to enclosing scope.
+// Recursive bindings are tied with fixpoint combinator Y = λf.((λx.(f (x x))) (λx.(f (x x)))):
+//
+//	letrec f = v in e => let f = (Y λf.v) in e
+//
+// and mutually recursive group uses fixpoint of tuple t (named by letrec keyword itself,
+// so it can't clash with user names) with projections in place of group names:
+//
+//	letrec f = u and g = v in e =>
+//	    let t = (Y λt.let f = (t Fst) in let g = (t Snd) in ((Tuple u) v)) in
+//	    let f = (t Fst) in let g = (t Snd) in e
+//
+// Combinators are closed, so they are inserted in de bruijn form right away,
+// the same goes for numeric literals, that become Church numerals
+func Desugar(source_code source.SourceCode, named_tree tree.Tree) tree.Tree {
+	return DesugarWithNumerals(source_code, named_tree, numeral.Church)
+}
+
+// DesugarWithNumerals is Desugar that encodes numeric literals with given encoding
+func DesugarWithNumerals(source_code source.SourceCode, named_tree tree.Tree, encoding numeral.Encoding) tree.Tree {
+	nodes := make([]tree.Node, 0, named_tree.Count())
+	add_node := func(node tree.Node) tree.NodeId {
+		nodes = append(nodes, node)
+		return tree.NodeId(len(nodes) - 1)
+	}
+
+	application := func(token source.TokenId, lhs, rhs tree.NodeId) tree.NodeId {
+		return add_node(tree.Node{
+			Tag:   tree.NodeApplication,
+			Token: token,
+			Lhs:   lhs,
+			Rhs:   rhs})
+	}
+	let_redex := func(token source.TokenId, bound, value, body tree.NodeId) tree.NodeId {
+		abstraction := add_node(tree.Node{
+			Tag:   tree.NodeAbstraction,
+			Token: token,
+			Lhs:   bound,
+			Rhs:   body})
+		return application(token, abstraction, value)
+	}
+	index := func(token source.TokenId, i int) tree.NodeId {
+		return add_node(tree.Node{
+			Tag:   tree.NodeIndexVariable,
+			Token: token,
+			Lhs:   tree.NodeId(i),
+			Rhs:   tree.NodeNull})
+	}
+	lambda := func(token source.TokenId, body tree.NodeId) tree.NodeId {
+		return add_node(tree.Node{
+			Tag:   tree.NodePureAbstraction,
+			Token: token,
+			Lhs:   body,
+			Rhs:   tree.NodeNull})
+	}
+	// λf.((λx.(f (x x))) (λx.(f (x x))))
+	fixpoint := func(token source.TokenId) tree.NodeId {
+		half := func() tree.NodeId {
+			self := application(token, index(token, 0), index(token, 0))
+			return lambda(token, application(token, index(token, 1), self))
+		}
+		return lambda(token, application(token, half(), half()))
+	}
+	// λx1...λxn.λs.(s x1 ... xn)
+	tuple := func(token source.TokenId, n int) tree.NodeId {
+		body := index(token, 0)
+		for i := n; i > 0; i-- {
+			body = application(token, body, index(token, i))
+		}
+		for i := 0; i <= n; i++ {
+			body = lambda(token, body)
+		}
+		return body
+	}
+	// λx1...λxn.xi
+	select_ := func(token source.TokenId, i, n int) tree.NodeId {
+		body := index(token, n-1-i)
+		for j := 0; j < n; j++ {
+			body = lambda(token, body)
+		}
+		return body
+	}
+
+	var aux func(tree.NodeId) tree.NodeId
+	aux = func(id tree.NodeId) tree.NodeId {
+		node := named_tree.Node(id)
+		switch node.Tag {
+		case tree.NodeNamedVariable, tree.NodeIndexVariable:
+			return add_node(node)
+		case tree.NodeApplication, tree.NodeAbstraction:
+			node.Lhs = aux(node.Lhs)
+			node.Rhs = aux(node.Rhs)
+			return add_node(node)
+		case tree.NodePureAbstraction:
+			node.Lhs = aux(node.Lhs)
+			return add_node(node)
+		case tree.NodeNumber:
+			value := ast.ToNumberNode(named_tree, node).Value()
+			encoded := numeral.Encode(encoding, value, node.Token)
+			offset := tree.NodeId(len(nodes))
+			for i := 0; i < encoded.Count(); i++ {
+				n := encoded.Node(tree.NodeId(i))
+				if lhs, _ := ast.NewNodeIterable(n).Children(); lhs != tree.NodeNull {
+					n.Lhs = lhs + offset
+				}
+				if _, rhs := ast.NewNodeIterable(n).Children(); rhs != tree.NodeNull {
+					n.Rhs = rhs + offset
+				}
+				add_node(n)
+			}
+			return encoded.RootId() + offset
+		case tree.NodeLet:
+			let := ast.ToLetNode(named_tree, node)
+			binding := ast.ToLetBindingNode(named_tree, named_tree.Node(let.Binding()))
+			return let_redex(node.Token, aux(binding.Bound()), aux(binding.Value()), aux(let.Body()))
+		case tree.NodeLetRec:
+			letrec := ast.ToLetRecNode(named_tree, node)
+			bindings := make([]ast.NodeIterable, 0, 2)
+			for list := letrec.Bindings(); list != tree.NodeNull; {
+				l := ast.ToLetRecBindingsNode(named_tree, named_tree.Node(list))
+				bindings = append(bindings, ast.ToLetBindingNode(named_tree, named_tree.Node(l.Binding())))
+				list = l.Next()
+			}
+			token := node.Token
+
+			if len(bindings) == 1 {
+				bound, value := bindings[0].Children()
+				functional := add_node(tree.Node{
+					Tag:   tree.NodeAbstraction,
+					Token: token,
+					Lhs:   aux(bound),
+					Rhs:   aux(value)})
+				fixed := application(token, fixpoint(token), functional)
+				return let_redex(token, aux(bound), fixed, aux(letrec.Body()))
+			}
+
+			tuple_variable := func() tree.NodeId {
+				return add_node(tree.Node{
+					Tag:   tree.NodeNamedVariable,
+					Token: token,
+					Lhs:   tree.NodeNull,
+					Rhs:   tree.NodeNull})
+			}
+			project := func(body tree.NodeId) tree.NodeId {
+				for i := len(bindings) - 1; i >= 0; i-- {
+					bound, _ := bindings[i].Children()
+					projection := application(token, tuple_variable(), select_(token, i, len(bindings)))
+					body = let_redex(token, aux(bound), projection, body)
+				}
+				return body
+			}
+			components := tuple(token, len(bindings))
+			for _, b := range bindings {
+				_, value := b.Children()
+				components = application(token, components, aux(value))
+			}
+			functional := add_node(tree.Node{
+				Tag:   tree.NodeAbstraction,
+				Token: token,
+				Lhs:   tuple_variable(),
+				Rhs:   project(components)})
+			fixed := application(token, fixpoint(token), functional)
+			return let_redex(token, tuple_variable(), fixed, project(aux(letrec.Body())))
+		default:
+			panic("Unreachable")
+		}
+	}
+
+	root := aux(named_tree.RootId())
+	return tree.NewTree(root, nodes)
+}
@@ -0,0 +1,129 @@
+package desugar
+
+import (
+	"errors"
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	debruijn "lambda/middle/de-bruijn"
+	"lambda/syntax/parser"
+	"lambda/syntax/source"
+	"lambda/util"
+	"testing"
+
+	"golang.org/x/exp/utf8string"
+)
+
+func testDesugar(text string) (string, error) {
+	source_code, coreTree, err := desugarText(text)
+	if err != nil {
+		return "", err
+	}
+	return ast.Print(source_code, coreTree, coreTree.RootId()), nil
+}
+
+func testDesugarIndices(text string) (string, error) {
+	source_code, coreTree, err := desugarText(text)
+	if err != nil {
+		return "", err
+	}
+	result := debruijn.ToDeBruijn(source_code, coreTree).Tree
+	return ast.Print(source_code, result, result.RootId()), nil
+}
+
+func desugarText(text string) (source.SourceCode, tree.Tree, error) {
+	logger := util.NewLogger()
+	tokenizer := parser.NewTokenizer(&logger)
+	source_code := tokenizer.Tokenize("test", *utf8string.NewString(text))
+	parser := parser.NewParserWithMode(&logger, parser.ModeNonStrict)
+	namedTree := parser.Parse(source_code)
+	if m, ok := logger.Next(); ok {
+		return source_code, namedTree, errors.New(m.String())
+	}
+
+	coreTree := Desugar(source_code, namedTree)
+	for i := 0; i < coreTree.Count(); i++ {
+		n := coreTree.Node(tree.NodeId(i))
+		switch n.Tag {
+		case tree.NodeNamedVariable, tree.NodeAbstraction, tree.NodeApplication,
+			tree.NodeIndexVariable, tree.NodePureAbstraction:
+		default:
+			return source_code, coreTree, errors.New("Encountered node that is not in core form")
+		}
+	}
+	return source_code, coreTree, nil
+}
+
+func TestDesugarLet(test *testing.T) {
+	cases := [...]struct{ text, expected string }{
+		{`let x = v in e`, `(λx.e) v`},
+		{`let x = x in x`, `(λx.x) x`},
+		{`λx.let y = x in x y`, `λx.(λy.x y) x`},
+		{`f (let g = a in g)`, `f ((λg.g) a)`},
+		{`let a = 1 in let b = a in let a = b in a b`, `(λa.(λb.(λa.a b) b) a) 1`},
+		{`let f = let g = h in g in f f`, `(λf.f f) ((λg.g) h)`},
+	}
+	for _, c := range cases {
+		got, err := testDesugar(c.text)
+		if err != nil {
+			test.Fatalf("%s: %s", c.text, err)
+		}
+		expected, err := testDesugar(c.expected)
+		if err != nil {
+			test.Fatalf("%s: %s", c.expected, err)
+		}
+		if got != expected {
+			test.Errorf("Got left, expected right\n%s", util.ConcatVertically(got, expected))
+		}
+	}
+}
+
+func TestDesugarLetRec(test *testing.T) {
+	Y := `(λf.(λx.f (x x)) (λx.f (x x)))`
+	cases := [...]struct{ text, expected string }{
+		{`letrec f = λn.f n in f`, `let f = ` + Y + ` (λf.λn.f n) in f`},
+		{`letrec f = f in letrec f = f in f`, `let f = ` + Y + ` (λf.f) in let f = ` + Y + ` (λf.f) in f`},
+		{
+			`letrec even = λn.odd n and odd = λn.even n in even`,
+			`let t = ` + Y + ` (λt.
+                let even = t (λa b.a) in
+                let odd = t (λa b.b) in
+                (λa b s.s a b) (λn.odd n) (λn.even n))
+            in
+            let even = t (λa b.a) in
+            let odd = t (λa b.b) in
+            even`,
+		},
+		{
+			`letrec a = b and b = c and c = a in c`,
+			`let t = ` + Y + ` (λt.
+                let a = t (λa b c.a) in
+                let b = t (λa b c.b) in
+                let c = t (λa b c.c) in
+                (λa b c s.s a b c) b c a)
+            in
+            let a = t (λa b c.a) in
+            let b = t (λa b c.b) in
+            let c = t (λa b c.c) in
+            c`,
+		},
+	}
+	for _, c := range cases {
+		got, err := testDesugarIndices(c.text)
+		if err != nil {
+			test.Fatalf("%s: %s", c.text, err)
+		}
+		expected, err := testDesugarIndices(c.expected)
+		if err != nil {
+			test.Fatalf("%s: %s", c.expected, err)
+		}
+		if got != expected {
+			test.Errorf("Got left, expected right\n%s", util.ConcatVertically(got, expected))
+		}
+	}
+}
+
+func TestDesugarLetRecDuplicateName(test *testing.T) {
+	if _, err := testDesugar(`letrec f = a and f = b in f`); err == nil {
+		test.Error("Expected error on duplicate name in letrec group")
+	}
+}
@@ -0,0 +1,170 @@
+package desugar
+
+import (
+	"fmt"
+	"lambda/ast/ast"
+	"lambda/ast/module"
+	"lambda/ast/tree"
+	"lambda/syntax/source"
+	"lambda/util"
+)
+
+// Program makes single named tree out of module, that evaluates definition with given name
+// (or main expression if entry is empty). Definitions can be used before they are defined
+// and can be recursive, so only those reachable from entry are bound, in dependency order:
+// group of definitions that refer to each other becomes letrec, every other one becomes let
+func Program(logger *util.Logger, source_code source.SourceCode, m module.Module, entry string) tree.Tree {
+	t := tree.NewMutableTree(m.Tree)
+	nodes := t.Nodes()
+	add_node := func(node tree.Node) tree.NodeId {
+		nodes = append(nodes, node)
+		return tree.NodeId(len(nodes) - 1)
+	}
+
+	root := m.Main
+	if entry != "" {
+		d, ok := m.Lookup(entry)
+		if !ok {
+			message := fmt.Sprintf("There is no definition named %s", entry)
+			logger.Add(util.NewMessage(util.Fatal, -1, -1, source_code.Filename(), message))
+			return t.Tree
+		}
+		root = add_node(m.Tree.Node(d.Bound))
+	} else if !m.HasMain() {
+		message := "Module has no main expression"
+		logger.Add(util.NewMessage(util.Fatal, -1, -1, source_code.Filename(), message))
+		return t.Tree
+	}
+
+	t.SetNodes(nodes)
+	for _, group := range dependencies(source_code, t.Tree, m, root) {
+		if len(group) == 1 && !refers_to(source_code, m.Tree, group[0].Value, group[0].Name) {
+			d := group[0]
+			binding := add_node(tree.Node{
+				Tag:   tree.NodeLetBinding,
+				Token: d.Token,
+				Lhs:   d.Bound,
+				Rhs:   d.Value})
+			root = add_node(tree.Node{
+				Tag:   tree.NodeLet,
+				Token: d.Token,
+				Lhs:   binding,
+				Rhs:   root})
+			continue
+		}
+
+		// keywords can't be names in either mode, so def keyword is fine as letrec token
+		token := group[0].Token
+		list := tree.NodeNull
+		for i := len(group) - 1; i >= 0; i-- {
+			d := group[i]
+			binding := add_node(tree.Node{
+				Tag:   tree.NodeLetBinding,
+				Token: d.Token,
+				Lhs:   d.Bound,
+				Rhs:   d.Value})
+			list = add_node(tree.Node{
+				Tag:   tree.NodeLetRecBindings,
+				Token: token,
+				Lhs:   binding,
+				Rhs:   list})
+		}
+		root = add_node(tree.Node{
+			Tag:   tree.NodeLetRec,
+			Token: token,
+			Lhs:   list,
+			Rhs:   root})
+	}
+
+	t.SetNodes(nodes)
+	t.SetRoot(root)
+	return t.Tree
+}
+
+// dependencies returns groups of mutually dependent definitions reachable from root,
+// innermost group first, so that every group depends only on groups after it
+func dependencies(source_code source.SourceCode, t tree.Tree, m module.Module, root tree.NodeId) [][]module.Definition {
+	// strongly connected components, found with Tarjan's algorithm, come out in
+	// reverse topological order
+	type vertex struct {
+		index, lowlink int
+		on_stack       bool
+	}
+	vertices := make(map[string]*vertex)
+	stack := util.NewStack[module.Definition]()
+	components := make([][]module.Definition, 0)
+
+	var connect func(d module.Definition)
+	connect = func(d module.Definition) {
+		v := &vertex{index: len(vertices), lowlink: len(vertices), on_stack: true}
+		vertices[d.Name] = v
+		stack.Push(d)
+
+		for _, name := range free_names(source_code, t, d.Value) {
+			next, ok := m.Lookup(name)
+			if !ok {
+				continue
+			}
+			w, visited := vertices[name]
+			if !visited {
+				connect(next)
+				v.lowlink = util.Min(v.lowlink, vertices[name].lowlink)
+			} else if w.on_stack {
+				v.lowlink = util.Min(v.lowlink, w.index)
+			}
+		}
+
+		if v.lowlink == v.index {
+			component := make([]module.Definition, 0, 1)
+			for {
+				top := stack.ForcePop()
+				vertices[top.Name].on_stack = false
+				component = append(component, top)
+				if top.Name == d.Name {
+					break
+				}
+			}
+			// keep source order inside of the group
+			for i, j := 0, len(component)-1; i < j; i, j = i+1, j-1 {
+				component[i], component[j] = component[j], component[i]
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, name := range free_names(source_code, t, root) {
+		if d, ok := m.Lookup(name); ok {
+			if _, visited := vertices[name]; !visited {
+				connect(d)
+			}
+		}
+	}
+
+	// groups closest to root are wrapped around it first
+	for i, j := 0, len(components)-1; i < j; i, j = i+1, j-1 {
+		components[i], components[j] = components[j], components[i]
+	}
+	return components
+}
+
+func refers_to(source_code source.SourceCode, t tree.Tree, root tree.NodeId, name string) bool {
+	for _, n := range free_names(source_code, t, root) {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// free_names lists names of free variables of named tree in order of their first occurrence
+func free_names(source_code source.SourceCode, t tree.Tree, root tree.NodeId) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	ast.TraverseFreeVariables(source_code, t, root, func(_ tree.NodeId, name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	})
+	return names
+}
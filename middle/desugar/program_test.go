@@ -0,0 +1,69 @@
+package desugar
+
+import (
+	"errors"
+	"lambda/ast/ast"
+	debruijn "lambda/middle/de-bruijn"
+	"lambda/syntax/parser"
+	"lambda/util"
+	"testing"
+
+	"golang.org/x/exp/utf8string"
+)
+
+func testProgram(text, entry string) (string, error) {
+	logger := util.NewLogger()
+	tokenizer := parser.NewTokenizer(&logger)
+	source_code := tokenizer.Tokenize("test", *utf8string.NewString(text))
+	parser := parser.NewParserWithMode(&logger, parser.ModeNonStrict)
+	m := parser.ParseModule(source_code)
+	namedTree := Program(&logger, source_code, m, entry)
+	if m, ok := logger.Next(); ok {
+		return "", errors.New(m.String())
+	}
+	result := debruijn.ToDeBruijn(source_code, Desugar(source_code, namedTree)).Tree
+	return ast.Print(source_code, result, result.RootId()), nil
+}
+
+func TestProgram(test *testing.T) {
+	module := `
+def Main = Twice Id
+def Twice = λf x.f (f x)
+def Unused = Omega
+def Id = λx.x
+def Omega = (λx.x x) (λx.x x)
+def Even = λn.IsZero n True (Odd (Pred n))
+def Odd = λn.IsZero n False (Even (Pred n))
+def Loop = Loop
+Twice a
+`
+	cases := [...]struct{ entry, expected string }{
+		{``, `let Twice = λf x.f (f x) in Twice a`},
+		{`Main`, `let Twice = λf x.f (f x) in let Id = λx.x in let Main = Twice Id in Main`},
+		{`Id`, `let Id = λx.x in Id`},
+		{`Loop`, `letrec Loop = Loop in Loop`},
+		{`Even`, `letrec Even = λn.IsZero n True (Odd (Pred n)) and Odd = λn.IsZero n False (Even (Pred n)) in Even`},
+	}
+	for _, c := range cases {
+		got, err := testProgram(module, c.entry)
+		if err != nil {
+			test.Fatalf("%s: %s", c.entry, err)
+		}
+		expected, err := testProgram(c.expected, "")
+		if err != nil {
+			test.Fatalf("%s: %s", c.expected, err)
+		}
+		if got != expected {
+			test.Errorf("%s: got left, expected right\n%s", c.entry, util.ConcatVertically(got, expected))
+		}
+	}
+}
+
+func TestProgramErrors(test *testing.T) {
+	if _, err := testProgram(`def a = b`, ``); err == nil {
+		test.Error("Expected error on module without main")
+	}
+	if _, err := testProgram(`def a = b`, `c`); err == nil {
+		test.Error("Expected error on unknown entry")
+	}
+}
@@ -0,0 +1,174 @@
+// Numeral encodes natural numbers as closed lambda terms in de bruijn form
+// and reads them back from normal forms
+package numeral
+
+import (
+	"lambda/ast/ast"
+	"lambda/ast/tree"
+	"lambda/syntax/source"
+)
+
+type Encoding int
+
+const (
+	// n = λf.λx.(f (f ... (f x))), n applications of f
+	Church Encoding = iota
+	// 0 = λs.λz.z, n+1 = λs.λz.(s n)
+	Scott
+	// Scott list of bits, least significant first, with nil = λn.λc.n and
+	// cons b rest = λn.λc.((c b) rest), where bit is boolean λt.λf.t or λt.λf.f
+	Binary
+)
+
+var encoding_names = [...]string{
+	Church: "church",
+	Scott:  "scott",
+	Binary: "binary",
+}
+
+func (e Encoding) String() string {
+	return encoding_names[e]
+}
+
+func ParseEncoding(name string) (Encoding, bool) {
+	for e, n := range encoding_names {
+		if n == name {
+			return Encoding(e), true
+		}
+	}
+	return Church, false
+}
+
+// Encode returns numeral n, all nodes of which point at given token
+func Encode(e Encoding, n int, token source.TokenId) tree.Tree {
+	nodes := make([]tree.Node, 0)
+	add_node := func(node tree.Node) tree.NodeId {
+		nodes = append(nodes, node)
+		return tree.NodeId(len(nodes) - 1)
+	}
+	index := func(i int) tree.NodeId {
+		return add_node(tree.Node{
+			Tag:   tree.NodeIndexVariable,
+			Token: token,
+			Lhs:   tree.NodeId(i),
+			Rhs:   tree.NodeNull})
+	}
+	lambda := func(body tree.NodeId) tree.NodeId {
+		return add_node(tree.Node{
+			Tag:   tree.NodePureAbstraction,
+			Token: token,
+			Lhs:   body,
+			Rhs:   tree.NodeNull})
+	}
+	application := func(lhs, rhs tree.NodeId) tree.NodeId {
+		return add_node(tree.Node{
+			Tag:   tree.NodeApplication,
+			Token: token,
+			Lhs:   lhs,
+			Rhs:   rhs})
+	}
+
+	root := tree.NodeNull
+	switch e {
+	case Church:
+		root = index(0)
+		for i := 0; i < n; i++ {
+			root = application(index(1), root)
+		}
+		root = lambda(lambda(root))
+	case Scott:
+		root = lambda(lambda(index(0)))
+		for i := 0; i < n; i++ {
+			root = lambda(lambda(application(index(1), root)))
+		}
+	case Binary:
+		bits := make([]int, 0)
+		for ; n > 0; n /= 2 {
+			bits = append(bits, n%2)
+		}
+		root = lambda(lambda(index(1)))
+		for i := len(bits) - 1; i >= 0; i-- {
+			bit := lambda(lambda(index(bits[i])))
+			root = lambda(lambda(application(application(index(0), bit), root)))
+		}
+	default:
+		panic("Unreachable")
+	}
+	return tree.NewTree(root, nodes)
+}
+
+// Decode reads numeral in normal form back, ok is false if term is not a numeral
+func Decode(e Encoding, t tree.Tree, root tree.NodeId) (n int, ok bool) {
+	// body of λ.λ.body, or NodeNull
+	body := func(id tree.NodeId) tree.NodeId {
+		for i := 0; i < 2; i++ {
+			node := t.Node(id)
+			if node.Tag != tree.NodePureAbstraction {
+				return tree.NodeNull
+			}
+			id = ast.ToPureAbstractionNode(t, node).Body()
+		}
+		return id
+	}
+	is_index := func(id tree.NodeId, i int) bool {
+		node := t.Node(id)
+		return node.Tag == tree.NodeIndexVariable && ast.ToIndexVariableNode(t, node).Index() == i
+	}
+	// lhs and rhs of (lhs rhs), or NodeNull
+	application := func(id tree.NodeId) (tree.NodeId, tree.NodeId) {
+		if id == tree.NodeNull || t.Node(id).Tag != tree.NodeApplication {
+			return tree.NodeNull, tree.NodeNull
+		}
+		return ast.ToApplicationNode(t, t.Node(id)).Children()
+	}
+
+	switch e {
+	case Church:
+		id := body(root)
+		for {
+			f, x := application(id)
+			if f == tree.NodeNull {
+				return n, id != tree.NodeNull && is_index(id, 0)
+			}
+			if !is_index(f, 1) {
+				return 0, false
+			}
+			n++
+			id = x
+		}
+	case Scott:
+		for id := root; ; n++ {
+			b := body(id)
+			if b != tree.NodeNull && is_index(b, 0) {
+				return n, true
+			}
+			s, rest := application(b)
+			if s == tree.NodeNull || !is_index(s, 1) {
+				return 0, false
+			}
+			id = rest
+		}
+	case Binary:
+		for id, weight := root, 1; ; weight *= 2 {
+			b := body(id)
+			if b != tree.NodeNull && is_index(b, 1) {
+				return n, true
+			}
+			cons, rest := application(b)
+			c, bit := application(cons)
+			if c == tree.NodeNull || !is_index(c, 0) {
+				return 0, false
+			}
+			switch v := body(bit); {
+			case v != tree.NodeNull && is_index(v, 1):
+				n += weight
+			case v != tree.NodeNull && is_index(v, 0):
+			default:
+				return 0, false
+			}
+			id = rest
+		}
+	default:
+		panic("Unreachable")
+	}
+}
@@ -0,0 +1,63 @@
+package numeral
+
+import (
+	"lambda/ast/ast"
+	"lambda/ast/sexpr"
+	"lambda/ast/tree"
+	"lambda/syntax/source"
+	"testing"
+)
+
+func TestEncode(test *testing.T) {
+	cases := [...]struct {
+		encoding Encoding
+		n        int
+		expected string
+	}{
+		{Church, 0, `(λ(λ 0))`},
+		{Church, 2, `(λ(λ( 1( 1 0))))`},
+		{Scott, 0, `(λ(λ 0))`},
+		{Scott, 1, `(λ(λ( 1(λ(λ 0)))))`},
+		{Binary, 0, `(λ(λ 1))`},
+		{Binary, 2, `(λ(λ(( 0(λ(λ 0)))(λ(λ(( 0(λ(λ 1)))(λ(λ 1))))))))`},
+	}
+	for _, c := range cases {
+		t := Encode(c.encoding, c.n, source.TokenInvalid)
+		got := ast.Print(source.SourceCode{}, t, t.RootId())
+		if sexpr.Minified(got) != sexpr.Minified(c.expected) {
+			test.Errorf("%s %d: expected %s got %s", c.encoding, c.n, c.expected, got)
+		}
+	}
+}
+
+func TestDecode(test *testing.T) {
+	for _, e := range [...]Encoding{Church, Scott, Binary} {
+		for _, n := range [...]int{0, 1, 2, 5, 8, 13, 100} {
+			t := Encode(e, n, source.TokenInvalid)
+			if got, ok := Decode(e, t, t.RootId()); !ok || got != n {
+				test.Errorf("%s: expected %d got %d", e, n, got)
+			}
+		}
+	}
+
+	// λx.x, λf.λx.(x f) and λf.λx.(f f) aren't numerals in any encoding
+	nodes := []tree.Node{
+		{Tag: tree.NodeIndexVariable, Lhs: 0, Rhs: tree.NodeNull},
+		{Tag: tree.NodePureAbstraction, Lhs: 0, Rhs: tree.NodeNull},
+		{Tag: tree.NodeIndexVariable, Lhs: 1, Rhs: tree.NodeNull},
+		{Tag: tree.NodeApplication, Lhs: 0, Rhs: 2},
+		{Tag: tree.NodePureAbstraction, Lhs: 3, Rhs: tree.NodeNull},
+		{Tag: tree.NodePureAbstraction, Lhs: 4, Rhs: tree.NodeNull},
+		{Tag: tree.NodeApplication, Lhs: 2, Rhs: 2},
+		{Tag: tree.NodePureAbstraction, Lhs: 6, Rhs: tree.NodeNull},
+		{Tag: tree.NodePureAbstraction, Lhs: 7, Rhs: tree.NodeNull},
+	}
+	for _, root := range [...]tree.NodeId{1, 5, 8} {
+		t := tree.NewTree(root, nodes)
+		for _, e := range [...]Encoding{Church, Scott, Binary} {
+			if n, ok := Decode(e, t, root); ok {
+				test.Errorf("%s: unexpected numeral %d", e, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Loader reads module together with modules it imports and links them into single module.
+// Imports are resolved relative to directory of importing module, every file is loaded once,
+// even if it is imported from several places. Definitions of imported modules get names
+// that can't be written in source, so they don't clash with each other:
+//
+//	import "num.lc" as N   -- N.Succ refers to num.lc#Succ
+//	import "bool.lc"       -- True refers to bool.lc#True, unless module defines True itself
+//
+// Only definitions of imported module are visible, not the ones it imports itself
+package loader
+
+import (
+	"fmt"
+	"lambda/ast/ast"
+	"lambda/ast/module"
+	"lambda/ast/tree"
+	"lambda/syntax/parser"
+	"lambda/syntax/source"
+	"lambda/util"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/utf8string"
+)
+
+// QualifiedSeparator joins file and definition names of imported definitions
+const QualifiedSeparator = "#"
+
+type target struct {
+	unit       *unit
+	definition int
+}
+
+type unit struct {
+	filename string
+	name     string // prefix of qualified names, empty for the root module
+	src      source.SourceCode
+	m        module.Module
+	imports  []*unit // parallel to m.Imports, nil when import failed
+	// references to definitions, found by resolve
+	references map[tree.NodeId]target
+
+	// filled by link
+	token_offset source.TokenId
+	node_offset  tree.NodeId
+	names        []string
+	tokens       []source.TokenId
+}
+
+type loader struct {
+	logger *util.Logger
+	mode   parser.Mode
+	root   string // directory of the root module
+	units  map[string]*unit
+	// chain of modules being loaded, used to report import cycles
+	loading []*unit
+	// dependencies come before modules that import them
+	order []*unit
+}
+
+// Load parses module from text of file with given name and everything it imports,
+// ok is false if logger got any messages on the way
+func Load(logger *util.Logger, mode parser.Mode, filename, text string) (source_code source.SourceCode, m module.Module, ok bool) {
+	l := loader{
+		logger: logger,
+		mode:   mode,
+		root:   filepath.Dir(filename),
+		units:  make(map[string]*unit),
+	}
+	root := l.load(filename, "", text)
+	if ok = logger.IsEmpty(); !ok {
+		return
+	}
+	for _, u := range l.order {
+		l.resolve(u)
+	}
+	if ok = logger.IsEmpty(); !ok {
+		return
+	}
+	source_code, m = l.link(root)
+	return
+}
+
+func (l *loader) load(filename, name, text string) *unit {
+	u := &unit{filename: filename, name: name}
+	if key, err := filepath.Abs(filename); err == nil {
+		l.units[key] = u
+	}
+
+	tokenizer := parser.NewTokenizer(l.logger)
+	u.src = tokenizer.Tokenize(filename, *utf8string.NewString(text))
+	if !l.logger.IsEmpty() {
+		return u
+	}
+	p := parser.NewParserWithMode(l.logger, l.mode)
+	u.m = p.ParseModule(u.src)
+	if !l.logger.IsEmpty() {
+		return u
+	}
+
+	l.loading = append(l.loading, u)
+	u.imports = make([]*unit, len(u.m.Imports))
+	for i, imp := range u.m.Imports {
+		u.imports[i] = l.load_import(u, imp)
+	}
+	l.loading = l.loading[:len(l.loading)-1]
+	l.order = append(l.order, u)
+	return u
+}
+
+func (l *loader) load_import(importer *unit, imp module.Import) *unit {
+	report := func(message string) {
+		c := importer.src.Token(imp.Token)
+		l.logger.Add(util.NewMessage(util.Fatal, c.Line, c.Col, importer.filename, message))
+	}
+
+	filename := imp.Path
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(filepath.Dir(importer.filename), filename)
+	}
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		report(err.Error())
+		return nil
+	}
+
+	if u, ok := l.units[key]; ok {
+		for i, v := range l.loading {
+			if v != u {
+				continue
+			}
+			cycle := make([]string, 0, len(l.loading)-i+1)
+			for _, v := range l.loading[i:] {
+				cycle = append(cycle, v.filename)
+			}
+			cycle = append(cycle, u.filename)
+			report("Import cycle " + strings.Join(cycle, " -> "))
+			return nil
+		}
+		return u
+	}
+
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		report(fmt.Sprintf("Can't import %s: %s", imp.Path, err))
+		return nil
+	}
+	name, err := filepath.Rel(l.root, filename)
+	if err != nil {
+		name = filename
+	}
+	return l.load(filename, filepath.ToSlash(name), string(text))
+}
+
+// resolve finds definitions that free variables of module refer to. Own definitions
+// shadow imported ones, name imported from several modules is ambiguous
+func (l *loader) resolve(u *unit) {
+	report := func(token source.TokenId, message string) {
+		c := u.src.Token(token)
+		l.logger.Add(util.NewMessage(util.Fatal, c.Line, c.Col, u.filename, message))
+	}
+
+	scope := make(map[string][]target)
+	for i, d := range u.m.Definitions {
+		scope[d.Name] = []target{{u, i}}
+	}
+	aliases := make(map[string]bool)
+	for i, imp := range u.m.Imports {
+		imported := u.imports[i]
+		if imp.Alias != "" {
+			if aliases[imp.Alias] {
+				report(imp.Token, fmt.Sprintf("Alias %s is used more than once", imp.Alias))
+			}
+			aliases[imp.Alias] = true
+		}
+		for j, d := range imported.m.Definitions {
+			name := d.Name
+			if imp.Alias != "" {
+				name = imp.Alias + string(source.TokenDotRune) + d.Name
+			}
+			targets := scope[name]
+			if len(targets) > 0 && (targets[0].unit == u || targets[0].unit == imported) {
+				continue
+			}
+			scope[name] = append(targets, target{imported, j})
+		}
+	}
+
+	u.references = make(map[tree.NodeId]target)
+	reference := func(id tree.NodeId, name string) {
+		token := u.m.Tree.Node(id).Token
+		targets := scope[name]
+		switch {
+		case len(targets) == 1:
+			u.references[id] = targets[0]
+		case len(targets) > 1:
+			report(token, fmt.Sprintf("Name %s is ambiguous, it is defined in %s and %s",
+				name, targets[0].unit.filename, targets[1].unit.filename))
+		case strings.ContainsRune(name, source.TokenDotRune):
+			report(token, fmt.Sprintf("Unknown qualified name %s", name))
+		}
+	}
+	for _, d := range u.m.Definitions {
+		ast.TraverseFreeVariables(u.src, u.m.Tree, d.Value, reference)
+	}
+	if u.m.HasMain() {
+		ast.TraverseFreeVariables(u.src, u.m.Tree, u.m.Main, reference)
+	}
+}
+
+// link puts all modules into single source and tree, references are retargeted to
+// tokens with names of definitions they refer to
+func (l *loader) link(root *unit) (source.SourceCode, module.Module) {
+	builder := source.NewBuilder(root.filename)
+	nodes := make([]tree.Node, 0)
+	for _, u := range l.order {
+		u.token_offset = builder.Append(u.src)
+		u.node_offset = tree.NodeId(len(nodes))
+		for id := 0; id < u.m.Tree.Count(); id++ {
+			node := u.m.Tree.Node(tree.NodeId(id))
+			lhs, rhs := ast.NewNodeIterable(node).Children()
+			if lhs != tree.NodeNull {
+				node.Lhs = lhs + u.node_offset
+			}
+			if rhs != tree.NodeNull {
+				node.Rhs = rhs + u.node_offset
+			}
+			node.Token += u.token_offset
+			nodes = append(nodes, node)
+		}
+	}
+
+	definitions := make([]module.Definition, 0)
+	for _, u := range l.order {
+		for _, d := range u.m.Definitions {
+			bound := d.Bound + u.node_offset
+			name, token := d.Name, nodes[bound].Token
+			if u != root {
+				name = u.name + QualifiedSeparator + d.Name
+				c := u.src.Token(u.m.Tree.Node(d.Bound).Token)
+				token = builder.AddToken(source.TokenIdentifier, name, c.Line, c.Col)
+				nodes[bound].Token = token
+			}
+			u.names = append(u.names, name)
+			u.tokens = append(u.tokens, token)
+			definitions = append(definitions, module.Definition{
+				Name:  name,
+				Token: d.Token + u.token_offset,
+				Bound: bound,
+				Value: d.Value + u.node_offset,
+				Start: d.Start + u.token_offset,
+				End:   d.End + u.token_offset,
+			})
+		}
+	}
+	for _, u := range l.order {
+		for id, t := range u.references {
+			nodes[id+u.node_offset].Token = t.unit.tokens[t.definition]
+		}
+	}
+
+	main := tree.NodeNull
+	if root.m.HasMain() {
+		main = root.m.Main + root.node_offset
+	}
+	return builder.SourceCode(), module.Module{
+		Tree:        tree.NewTree(main, nodes),
+		Definitions: definitions,
+		Main:        main,
+		MainStart:   root.m.MainStart + root.token_offset,
+	}
+}
@@ -0,0 +1,119 @@
+package loader
+
+import (
+	"lambda/ast/ast"
+	"lambda/syntax/parser"
+	"lambda/util"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(test *testing.T, files map[string]string) string {
+	dir := test.TempDir()
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			test.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			test.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var library = map[string]string{
+	"bool.lc": `
+def True = λt f.t
+def False = λt f.f
+def Not = λp.p False True
+`,
+	"lib/num.lc": `
+import "../bool.lc"
+def Zero = λf x.x
+def Succ = λn f x.f (n f x)
+def IsZero = λn.n (λx.False) True
+`,
+}
+
+func TestLoad(test *testing.T) {
+	dir := writeFiles(test, library)
+	text := `
+import "bool.lc"
+import "lib/num.lc" as N
+def True = λx.x
+def Test = N.IsZero (N.Succ N.Zero)
+Not (Test True)
+`
+	logger := util.NewLogger()
+	source_code, m, ok := Load(&logger, parser.ModeNonStrict, filepath.Join(dir, "main.lc"), text)
+	if !ok {
+		m, _ := logger.Next()
+		test.Fatal(m)
+	}
+
+	// bool.lc is loaded once, though both modules import it
+	names := make([]string, 0)
+	for _, d := range m.Definitions {
+		names = append(names, d.Name)
+	}
+	expected := "bool.lc#True bool.lc#False bool.lc#Not lib/num.lc#Zero lib/num.lc#Succ lib/num.lc#IsZero True Test"
+	if got := strings.Join(names, " "); got != expected {
+		test.Errorf("Unexpected definitions %s", got)
+	}
+
+	cases := [...]struct{ name, value string }{
+		{"Test", `( lib/num.lc#IsZero( lib/num.lc#Succ lib/num.lc#Zero))`},
+		{"lib/num.lc#IsZero", `(λ n(( n(λ x bool.lc#False)) bool.lc#True))`},
+		{"bool.lc#Not", `(λ p(( p bool.lc#False) bool.lc#True))`},
+	}
+	for _, c := range cases {
+		d, ok := m.Lookup(c.name)
+		if !ok {
+			test.Fatalf("No definition %s", c.name)
+		}
+		if got := ast.Print(source_code, m.Tree, d.Value); got != c.value {
+			test.Errorf("%s: expected %s got %s", c.name, c.value, got)
+		}
+	}
+	// own definition shadows imported one
+	if got := ast.Print(source_code, m.Tree, m.Main); got != `( bool.lc#Not( Test True))` {
+		test.Errorf("Unexpected main %s", got)
+	}
+}
+
+func TestLoadErrors(test *testing.T) {
+	files := map[string]string{
+		"a.lc":      "import \"b.lc\"\ndef A = λx.x",
+		"b.lc":      "\nimport \"a.lc\"\ndef B = λx.x",
+		"other.lc":  "def True = λx.x",
+		"broken.lc": "def X = (",
+	}
+	for k, v := range library {
+		files[k] = v
+	}
+	dir := writeFiles(test, files)
+
+	cases := [...]struct{ text, message string }{
+		{`import "a.lc"` + "\nA", "Fatal at " + filepath.Join(dir, "b.lc") + ":2:0 Import cycle"},
+		{`import "main.lc"` + "\nx", "Fatal at " + filepath.Join(dir, "main.lc") + ":1:0 Import cycle"},
+		{"\n  " + `import "nope.lc"`, "Fatal at " + filepath.Join(dir, "main.lc") + ":2:2 Can't import nope.lc"},
+		{`import "broken.lc"`, "Fatal at " + filepath.Join(dir, "broken.lc")},
+		{`import "bool.lc" as B` + "\nB.Nope", "Fatal at " + filepath.Join(dir, "main.lc") + ":2:0 Unknown qualified name B.Nope"},
+		{`import "bool.lc"` + "\n" + `import "other.lc"` + "\nTrue", "Name True is ambiguous"},
+		{`import "bool.lc" as B` + "\n" + `import "other.lc" as B` + "\nB.Not", "Alias B is used more than once"},
+		{`def N.x = y`, "Qualified name N.x can't be bound"},
+		{`import "bool.lc" as N.B`, "Unexpected"},
+		{`import "bool.lc`, "Unterminated string"},
+	}
+	for _, c := range cases {
+		logger := util.NewLogger()
+		_, _, ok := Load(&logger, parser.ModeNonStrict, filepath.Join(dir, "main.lc"), c.text)
+		m, _ := logger.Next()
+		if ok || !strings.Contains(m.String(), c.message) {
+			test.Errorf("Expected %q on %q, got %q", c.message, c.text, m)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package parser
 
 import (
 	"fmt"
+	"lambda/ast/module"
 	"lambda/ast/tree"
 	"lambda/syntax/source"
 	"lambda/util"
+	"strconv"
+	"strings"
 	"unicode"
 
 	"golang.org/x/exp/utf8string"
@@ -21,26 +24,75 @@ func NewTokenizer(logger *util.Logger) tokenizer {
 func (tok tokenizer) Tokenize(filename string, text utf8string.String) source.SourceCode {
 	tokens := make([]source.Token, 0, 16)
 	pos := 0
-	line, col := 1, 0
+	line, line_start := 1, 0
 
 	add_token := func(tag source.TokenId, length int) {
 		start, end := pos, pos+length
-		tokens = append(tokens, source.NewToken(tag, start, end, line, col))
+		tokens = append(tokens, source.NewToken(tag, start, end, line, start-line_start))
 		pos = end
-		col = end
 	}
 
-	skip_spaces := func() {
+	advance := func() {
+		if text.At(pos) == '\n' {
+			line++
+			line_start = pos + 1
+		}
+		pos++
+	}
+
+	starts_with := func(prefix string) bool {
+		i := pos
+		for _, r := range prefix {
+			if i >= text.RuneCount() || text.At(i) != r {
+				return false
+			}
+			i++
+		}
+		return true
+	}
+
+	skip_line_comment := func() {
+		for pos < text.RuneCount() && text.At(pos) != '\n' {
+			advance()
+		}
+	}
+
+	skip_block_comment := func() {
+		start_line, start_col := line, pos-line_start
+		depth := 0
 		for pos < text.RuneCount() {
-			c := text.At(pos)
-			if !unicode.IsSpace(c) {
-				break
+			switch {
+			case starts_with(source.BlockCommentOpen):
+				depth++
+				advance()
+				advance()
+			case starts_with(source.BlockCommentClose):
+				depth--
+				advance()
+				advance()
+				if depth == 0 {
+					return
+				}
+			default:
+				advance()
 			}
-			if c == '\n' {
-				line++
-				col = 0
+		}
+		message := "Unterminated block comment"
+		tok.logger.Add(util.NewMessage(util.Fatal, start_line, start_col, filename, message))
+	}
+
+	skip_spaces := func() {
+		for pos < text.RuneCount() {
+			switch {
+			case unicode.IsSpace(text.At(pos)):
+				advance()
+			case starts_with(source.LineComment), starts_with(source.LineCommentHash):
+				skip_line_comment()
+			case starts_with(source.BlockCommentOpen):
+				skip_block_comment()
+			default:
+				return
 			}
-			pos++
 		}
 	}
 
@@ -50,12 +102,21 @@ func (tok tokenizer) Tokenize(filename string, text utf8string.String) source.So
 			r != source.TokenLambdaBackslashRune &&
 			r != source.TokenLeftParenRune &&
 			r != source.TokenRightParenRune &&
+			r != source.TokenStringRune &&
+			string(r) != source.LineCommentHash &&
 			!unicode.IsSpace(r)
 	}
+	// outside of binders identifiers joined by dots make qualified name, like N.Succ
+	binders := false
 	identifier_length := func() int {
 		start, end := pos, pos
 		for end < text.RuneCount() {
 			c := text.At(end)
+			if c == source.TokenDotRune && !binders && end > start &&
+				end+1 < text.RuneCount() && identifier_rune(text.At(end+1)) {
+				end++
+				continue
+			}
 			if !identifier_rune(c) {
 				return end - start
 			}
@@ -63,6 +124,18 @@ func (tok tokenizer) Tokenize(filename string, text utf8string.String) source.So
 		}
 		return end - start
 	}
+	string_length := func() int {
+		end := pos + 1
+		for end < text.RuneCount() && text.At(end) != '\n' {
+			if text.At(end) == source.TokenStringRune {
+				return end + 1 - pos
+			}
+			end++
+		}
+		message := "Unterminated string"
+		tok.logger.Add(util.NewMessage(util.Fatal, line, pos-line_start, filename, message))
+		return end - pos
+	}
 
 	for {
 		skip_spaces()
@@ -73,10 +146,12 @@ func (tok tokenizer) Tokenize(filename string, text utf8string.String) source.So
 		switch text.At(pos) {
 		case source.TokenDotRune:
 			add_token(source.TokenDot, 1)
-		case source.TokenLambdaRune:
-			add_token(source.TokenLambda, 1)
-		case source.TokenLambdaBackslashRune:
+			binders = false
+		case source.TokenLambdaRune, source.TokenLambdaBackslashRune:
 			add_token(source.TokenLambda, 1)
+			binders = true
+		case source.TokenStringRune:
+			add_token(source.TokenString, string_length())
 		case source.TokenLeftParenRune:
 			add_token(source.TokenLeftParen, 1)
 		case source.TokenRightParenRune:
@@ -84,7 +159,13 @@ func (tok tokenizer) Tokenize(filename string, text utf8string.String) source.So
 		default:
 			length := identifier_length()
 			if length > 0 {
-				add_token(source.TokenIdentifier, length)
+				tag := source.TokenNumber
+				for i := pos; i < pos+length; i++ {
+					if r := text.At(i); r < '0' || r > '9' {
+						tag = source.TokenIdentifier
+					}
+				}
+				add_token(tag, length)
 			}
 		}
 	}
@@ -93,8 +174,27 @@ func (tok tokenizer) Tokenize(filename string, text utf8string.String) source.So
 	return source.NewSourceCode(filename, text, tokens)
 }
 
+// numeral of literal is built whole before evaluation and is as large as its value,
+// so larger literals are rejected
+const max_numeral = 1 << 16
+
+type Mode int
+
+const (
+	// Unary abstractions and mandatory parens around application of exactly two terms
+	ModeStrict Mode = iota
+	// Abstractions may bind several variables, applications are written by juxtaposition:
+	// they are left-associative and abstraction body extends as far right as possible
+	ModeNonStrict
+)
+
 type parser struct {
-	src source.SourceCode
+	src  source.SourceCode
+	mode Mode
+	// top level items of module start at the first column, so application
+	// can't continue there, unless it is inside parens
+	layout bool
+	parens int
 
 	ast_nodes []tree.Node
 	current   source.TokenId
@@ -135,7 +235,8 @@ func (p *parser) expect(tag source.TokenId, lexeme string) (ok bool) {
 		return
 	}
 
-	ok = p.matchTag(tag)
+	// keywords are identifiers, so lexeme tells them apart
+	ok = p.matchTag(tag) && (lexeme == "" || p.src.Lexeme(p.current) == lexeme)
 	if !ok {
 		c := p.src.Token(p.current)
 		got := p.src.TraceToken(c.Tag, p.src.Lexeme(p.current), c.Line, c.Col)
@@ -147,19 +248,40 @@ func (p *parser) expect(tag source.TokenId, lexeme string) (ok bool) {
 	return
 }
 
+func (p *parser) unexpected() {
+	if p.atEof {
+		p.logger.Add(util.NewMessage(util.Fatal, -1, -1, p.src.Filename(), "Unexpected EOF"))
+		return
+	}
+	c := p.src.Token(p.current)
+	message := fmt.Sprintf("\nUnexpected\n %s", p.src.TraceToken(c.Tag, p.src.Lexeme(p.current), c.Line, c.Col))
+	p.logger.Add(util.NewMessage(util.Fatal, c.Line, c.Col, p.src.Filename(), message))
+}
+
 func (p *parser) new_node(node tree.Node) tree.NodeId {
 	p.ast_nodes = append(p.ast_nodes, node)
 	return tree.NodeId(len(p.ast_nodes) - 1)
 }
 
+func (p *parser) matchKeyword(lexeme string) bool {
+	return p.matchTag(source.TokenIdentifier) && p.src.Lexeme(p.current) == lexeme
+}
+
 func NewParser(logger *util.Logger) parser {
+	return NewParserWithMode(logger, ModeStrict)
+}
+
+func NewParserWithMode(logger *util.Logger, mode Mode) parser {
 	return parser{
+		mode:   mode,
 		logger: logger,
 	}
 }
 
 func (p *parser) Parse(src source.SourceCode) tree.Tree {
 	p.src = src
+	p.current = 0
+	p.atEof = src.Token(p.current).Tag == source.TokenEof
 
 	root := p.parse_term()
 	if !p.atEof {
@@ -171,9 +293,99 @@ func (p *parser) Parse(src source.SourceCode) tree.Tree {
 	return tree.NewTree(root, p.ast_nodes)
 }
 
+// ParseModule parses imports, then sequence of definitions followed by optional main term.
+// In non-strict mode line that starts at the first column outside of parens begins new definition or main term
+func (p *parser) ParseModule(src source.SourceCode) module.Module {
+	p.src = src
+	p.current = 0
+	p.atEof = src.Token(p.current).Tag == source.TokenEof
+	p.layout = p.mode == ModeNonStrict
+	defer func() { p.layout = false }()
+
+	imports := make([]module.Import, 0, 2)
+	for p.matchKeyword("import") {
+		token := p.current
+		p.next()
+		path_token := p.current
+		if !p.expect(source.TokenString, "") {
+			continue
+		}
+		path := strings.Trim(p.src.Lexeme(path_token), string(source.TokenStringRune))
+		alias := ""
+		if p.matchKeyword("as") {
+			p.next()
+			alias_token := p.current
+			if p.matchTag(source.TokenIdentifier) && !source.IsKeyword(p.src.Lexeme(alias_token)) &&
+				!strings.ContainsRune(p.src.Lexeme(alias_token), source.TokenDotRune) {
+				alias = p.src.Lexeme(alias_token)
+				p.next()
+			} else {
+				p.unexpected()
+				continue
+			}
+		}
+		imports = append(imports, module.Import{Token: token, Path: path, Alias: alias})
+	}
+
+	definitions := make([]module.Definition, 0, 8)
+	for p.matchKeyword("def") {
+		token := p.current
+		p.next()
+		name_token := p.current
+		bound := p.parse_binder()
+		p.expect(source.TokenIdentifier, "=")
+		start := p.current
+		value := p.parse_term()
+		if bound == tree.NodeInvalid {
+			continue
+		}
+
+		name := p.src.Lexeme(name_token)
+		for _, d := range definitions {
+			if d.Name == name {
+				c := p.src.Token(name_token)
+				message := fmt.Sprintf("Name %s is defined more than once", name)
+				p.logger.Add(util.NewMessage(util.Fatal, c.Line, c.Col, p.src.Filename(), message))
+			}
+		}
+		definitions = append(definitions, module.Definition{
+			Name:  name,
+			Token: token,
+			Bound: bound,
+			Value: value,
+			Start: start,
+			End:   p.current,
+		})
+	}
+
+	main, main_start := tree.NodeNull, p.current
+	if !p.atEof {
+		main = p.parse_term()
+	}
+	if !p.atEof {
+		message := "Unexpected EOF"
+		p.logger.Add(util.NewMessage(util.Fatal, -1, -1, p.src.Filename(), message))
+	}
+
+	return module.Module{
+		Imports:     imports,
+		Tree:        tree.NewTree(main, p.ast_nodes),
+		Definitions: definitions,
+		Main:        main,
+		MainStart:   main_start,
+	}
+}
+
 func (p *parser) parse_term() tree.NodeId {
+	if p.mode == ModeNonStrict {
+		return p.parse_nonstrict_term()
+	}
+
 	id := tree.NodeInvalid
 
+	if p.matchTag(source.TokenNumber) {
+		return p.parse_number()
+	}
 	if !p.matchTag(source.TokenIdentifier) {
 		open_paren := p.matchTag(source.TokenLeftParen)
 
@@ -182,8 +394,11 @@ func (p *parser) parse_term() tree.NodeId {
 		}
 		if p.matchTag(source.TokenLambda) {
 			id = p.parse_abstraction()
-		} else {
+		} else if open_paren {
 			id = p.parse_application()
+		} else {
+			p.unexpected()
+			return id
 		}
 		if open_paren {
 			p.expect(source.TokenRightParen, "")
@@ -201,10 +416,21 @@ func (p *parser) parse_variable() tree.NodeId {
 
 	tag = tree.NodeNamedVariable
 	token = p.current
+	if !p.matchTag(source.TokenIdentifier) {
+		p.unexpected()
+		return tree.NodeInvalid
+	}
 	identifier := p.src.Lexeme(token)
 	if identifier == "let" {
 		return p.parse_let_binding()
 	}
+	if identifier == "letrec" {
+		return p.parse_letrec_binding()
+	}
+	if source.IsKeyword(identifier) {
+		p.unexpected()
+		return tree.NodeInvalid
+	}
 
 	p.next()
 
@@ -215,6 +441,38 @@ func (p *parser) parse_variable() tree.NodeId {
 		Rhs:   rhs})
 }
 
+// Numeric literal keeps its value in place of lhs, as index variable does
+func (p *parser) parse_number() tree.NodeId {
+	token := p.current
+	value, err := strconv.Atoi(p.src.Lexeme(token))
+	if err != nil || value > max_numeral {
+		c := p.src.Token(token)
+		message := fmt.Sprintf("Numeral %s is too large, at most %d is allowed", p.src.Lexeme(token), max_numeral)
+		p.logger.Add(util.NewMessage(util.Fatal, c.Line, c.Col, p.src.Filename(), message))
+		value = 0
+	}
+	p.next()
+
+	return p.new_node(tree.Node{
+		Tag:   tree.NodeNumber,
+		Token: token,
+		Lhs:   tree.NodeId(value),
+		Rhs:   tree.NodeNull})
+}
+
+// Binders are plain variables, qualified names refer only to imported definitions
+func (p *parser) parse_binder() tree.NodeId {
+	token := p.current
+	id := p.parse_variable()
+	if id != tree.NodeInvalid && p.src.Token(token).Tag == source.TokenIdentifier &&
+		strings.ContainsRune(p.src.Lexeme(token), source.TokenDotRune) {
+		c := p.src.Token(token)
+		message := fmt.Sprintf("Qualified name %s can't be bound", p.src.Lexeme(token))
+		p.logger.Add(util.NewMessage(util.Fatal, c.Line, c.Col, p.src.Filename(), message))
+	}
+	return id
+}
+
 func (p *parser) parse_application() tree.NodeId {
 	tag, token, lhs, rhs := tree.NodeInvalid, source.TokenInvalid, tree.NodeInvalid, tree.NodeInvalid
 
@@ -237,8 +495,12 @@ func (p *parser) parse_abstraction() tree.NodeId {
 	token = p.current
 	p.expect(source.TokenLambda, "")
 	lhs = p.parse_variable()
-	p.expect(source.TokenDot, "")
-	rhs = p.parse_term()
+	if p.mode == ModeNonStrict && !p.matchTag(source.TokenDot) {
+		rhs = p.parse_abstraction_binders(token)
+	} else {
+		p.expect(source.TokenDot, "")
+		rhs = p.parse_term()
+	}
 
 	return p.new_node(tree.Node{
 		Tag:   tag,
@@ -247,26 +509,162 @@ func (p *parser) parse_abstraction() tree.NodeId {
 		Rhs:   rhs})
 }
 
-// BUG: I ignore all notion of name scoping in let, this is unacceptable
+// Let is kept as is, it is up to desugaring stage to rewrite it into redex. Bound variable
+// is in scope of the body only, variable with the same name in value refers to outer binding
 func (p *parser) parse_let_binding() tree.NodeId {
 
 	token := p.current
 	p.expect(source.TokenIdentifier, "let")
-	bound := p.parse_variable()
+	bound := p.parse_binder()
+	binding_token := p.current
 	p.expect(source.TokenIdentifier, "=")
 	value := p.parse_term()
 	p.expect(source.TokenIdentifier, "in")
 	expr := p.parse_term()
 
-	absraction := p.new_node(tree.Node{
-		Tag:   tree.NodeAbstraction,
-		Token: token,
+	binding := p.new_node(tree.Node{
+		Tag:   tree.NodeLetBinding,
+		Token: binding_token,
 		Lhs:   bound,
+		Rhs:   value})
+
+	return p.new_node(tree.Node{
+		Tag:   tree.NodeLet,
+		Token: token,
+		Lhs:   binding,
 		Rhs:   expr})
+}
+
+// Bindings of letrec group are visible in every value of the group and in the body
+func (p *parser) parse_letrec_binding() tree.NodeId {
+	token := p.current
+	p.expect(source.TokenIdentifier, "letrec")
+
+	bindings := make([]tree.NodeId, 0, 2)
+	names := make(map[string]bool)
+	for {
+		bound_token := p.current
+		bound := p.parse_binder()
+		if bound != tree.NodeInvalid {
+			name := p.src.Lexeme(bound_token)
+			if names[name] {
+				c := p.src.Token(bound_token)
+				message := fmt.Sprintf("Name %s is bound more than once in letrec", name)
+				p.logger.Add(util.NewMessage(util.Fatal, c.Line, c.Col, p.src.Filename(), message))
+			}
+			names[name] = true
+		}
+		binding_token := p.current
+		p.expect(source.TokenIdentifier, "=")
+		value := p.parse_term()
+		bindings = append(bindings, p.new_node(tree.Node{
+			Tag:   tree.NodeLetBinding,
+			Token: binding_token,
+			Lhs:   bound,
+			Rhs:   value}))
+
+		if !p.matchKeyword("and") {
+			break
+		}
+		p.next()
+	}
+	p.expect(source.TokenIdentifier, "in")
+	expr := p.parse_term()
+
+	list := tree.NodeNull
+	for i := len(bindings) - 1; i >= 0; i-- {
+		list = p.new_node(tree.Node{
+			Tag:   tree.NodeLetRecBindings,
+			Token: token,
+			Lhs:   bindings[i],
+			Rhs:   list})
+	}
 
 	return p.new_node(tree.Node{
-		Tag:   tree.NodeApplication,
+		Tag:   tree.NodeLetRec,
 		Token: token,
-		Lhs:   absraction,
-		Rhs:   value})
+		Lhs:   list,
+		Rhs:   expr})
+}
+
+// Rest of binders of non-unary abstraction, λx y z.body is the same as λx.λy.λz.body
+func (p *parser) parse_abstraction_binders(token source.TokenId) tree.NodeId {
+	lhs := p.parse_variable()
+	rhs := tree.NodeInvalid
+	if p.matchTag(source.TokenDot) || lhs == tree.NodeInvalid {
+		p.expect(source.TokenDot, "")
+		rhs = p.parse_term()
+	} else {
+		rhs = p.parse_abstraction_binders(token)
+	}
+
+	return p.new_node(tree.Node{
+		Tag:   tree.NodeAbstraction,
+		Token: token,
+		Lhs:   lhs,
+		Rhs:   rhs})
+}
+
+func (p *parser) parse_nonstrict_term() tree.NodeId {
+	if p.matchTag(source.TokenLambda) {
+		return p.parse_abstraction()
+	}
+	if p.matchKeyword("let") {
+		return p.parse_let_binding()
+	}
+	if p.matchKeyword("letrec") {
+		return p.parse_letrec_binding()
+	}
+	return p.parse_juxtaposition()
+}
+
+// Application written as sequence of terms, f a b λx.x y is the same as ((f a) b) (λx.(x y))
+func (p *parser) parse_juxtaposition() tree.NodeId {
+	token := p.current
+	lhs := p.parse_atom()
+	for lhs != tree.NodeInvalid {
+		rhs := tree.NodeInvalid
+		if p.layout && p.parens == 0 && !p.atEof && p.src.Token(p.current).Col == 0 {
+			break
+		}
+		if p.matchTag(source.TokenLambda) || p.matchKeyword("let") || p.matchKeyword("letrec") {
+			rhs = p.parse_nonstrict_term()
+		} else if p.starts_atom() {
+			rhs = p.parse_atom()
+		} else {
+			break
+		}
+		if rhs == tree.NodeInvalid {
+			return rhs
+		}
+
+		lhs = p.new_node(tree.Node{
+			Tag:   tree.NodeApplication,
+			Token: token,
+			Lhs:   lhs,
+			Rhs:   rhs})
+	}
+	return lhs
+}
+
+func (p *parser) starts_atom() bool {
+	if p.matchTag(source.TokenLeftParen) || p.matchTag(source.TokenNumber) {
+		return true
+	}
+	return p.matchTag(source.TokenIdentifier) && !source.IsKeyword(p.src.Lexeme(p.current))
+}
+
+func (p *parser) parse_atom() tree.NodeId {
+	if p.matchTag(source.TokenNumber) {
+		return p.parse_number()
+	}
+	if !p.matchTag(source.TokenLeftParen) {
+		return p.parse_variable()
+	}
+	p.expect(source.TokenLeftParen, "")
+	p.parens++
+	id := p.parse_term()
+	p.parens--
+	p.expect(source.TokenRightParen, "")
+	return id
 }
@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"errors"
+	"lambda/ast/ast"
 	"lambda/syntax/source"
 	"lambda/util"
+	"strings"
 	"testing"
 
 	"golang.org/x/exp/utf8string"
@@ -47,3 +50,284 @@ func TestTokenizer(test *testing.T) {
 		}
 	}
 }
+
+func parseWithMode(text string, mode Mode) (string, error) {
+	logger := util.NewLogger()
+	tokenizer := NewTokenizer(&logger)
+	source_code := tokenizer.Tokenize("test", *utf8string.NewString(text))
+	parser := NewParserWithMode(&logger, mode)
+	t := parser.Parse(source_code)
+	if m, ok := logger.Next(); ok {
+		return "", errors.New(m.String())
+	}
+	return ast.Print(source_code, t, t.RootId()), nil
+}
+
+func TestNonStrictParser(test *testing.T) {
+	cases := [...]struct{ nonstrict, strict string }{
+		{`f a b c`, `(((f a) b) c)`},
+		{`λx y z.x z (y z)`, `λx.λy.λz.((x z) (y z))`},
+		{`f λx.x y`, `(f λx.(x y))`},
+		{`(λx.x) λy.y`, `((λx.x) λy.y)`},
+		{`f (g a b) c`, `((f ((g a) b)) c)`},
+		{`((((λx.x) a))) b`, `(((λx.x) a) b)`},
+		{`let I = λx.x in I I a`, `let I = λx.x in ((I I) a)`},
+		{`f let a = b in a c`, `(f let a = b in (a c))`},
+		{`λf.let g = f f in g g`, `λf.let g = (f f) in (g g)`},
+		{`(f x)`, `(f x)`},
+		{`f 1 (g 23)`, `((f 1) (g 23))`},
+	}
+	for _, c := range cases {
+		got, err := parseWithMode(c.nonstrict, ModeNonStrict)
+		if err != nil {
+			test.Errorf("%s: %s", c.nonstrict, err)
+			continue
+		}
+		expected, err := parseWithMode(c.strict, ModeStrict)
+		if err != nil {
+			test.Fatalf("%s: %s", c.strict, err)
+		}
+		if got != expected {
+			test.Errorf("Got left, expected right\n%s", util.ConcatVertically(got, expected))
+		}
+	}
+}
+
+func TestNonStrictParserErrors(test *testing.T) {
+	for _, text := range [...]string{`λ.x`, `λx y`, `f )`, `(f x`, `let x = in x`, `f in`, ``, `λ1.x`, `let 2 = x in x`, `99999999999999999999`} {
+		if _, err := parseWithMode(text, ModeNonStrict); err == nil {
+			test.Errorf("Expected error on %q", text)
+		}
+	}
+}
+
+func TestParserLimitsNumerals(test *testing.T) {
+	if _, err := parseWithMode(`(f 65536)`, ModeNonStrict); err != nil {
+		test.Error(err)
+	}
+	logger := util.NewLogger()
+	tokenizer := NewTokenizer(&logger)
+	source_code := tokenizer.Tokenize("test", *utf8string.NewString("f\n  2000000000"))
+	parser := NewParserWithMode(&logger, ModeNonStrict)
+	parser.Parse(source_code)
+	m, ok := logger.Next()
+	if !ok || !strings.HasPrefix(m.String(), "Fatal at test:2:2 Numeral 2000000000 is too large") {
+		test.Errorf("Expected error at large numeral, got %v", m)
+	}
+}
+
+func TestParserChecksKeywordsOfLetRec(test *testing.T) {
+	// any identifier used to pass for = and in
+	for _, text := range [...]string{`letrec f x λy.y in f`, `letrec f = λy.y x f`, `letrec f = a and g x b in f`, `let x y a in x`} {
+		for _, mode := range [...]Mode{ModeStrict, ModeNonStrict} {
+			if _, err := parseWithMode(text, mode); err == nil {
+				test.Errorf("Expected error on %q in mode %d", text, mode)
+			}
+		}
+	}
+}
+
+func TestStrictParserRejectsNonStrictSyntax(test *testing.T) {
+	for _, text := range [...]string{`f a`, `(f a b)`, `λx y.x`} {
+		if _, err := parseWithMode(text, ModeStrict); err == nil {
+			test.Errorf("Expected error on %q", text)
+		}
+	}
+}
+
+func TestParserRejectsKeywordNames(test *testing.T) {
+	// desugaring names made up variables after keywords, so they must not be captured
+	for _, text := range [...]string{`λdef.def`, `(f def)`, `λletrec.x`, `λin.x`} {
+		for _, mode := range [...]Mode{ModeStrict, ModeNonStrict} {
+			if _, err := parseWithMode(text, mode); err == nil {
+				test.Errorf("Expected error on %q in mode %d", text, mode)
+			}
+		}
+	}
+}
+
+func TestTokenizerComments(test *testing.T) {
+	text := utf8string.NewString(`-- line comment
+λx.x# comment right after identifier
+{- block {- nested
+   -} still comment -} (f -7) {--}y
+# last line`)
+	expected := [...]struct {
+		tag       source.TokenId
+		lexeme    string
+		line, col int
+	}{
+		{source.TokenLambda, `λ`, 2, 0},
+		{source.TokenIdentifier, "x", 2, 1},
+		{source.TokenDot, `.`, 2, 2},
+		{source.TokenIdentifier, "x", 2, 3},
+		{source.TokenLeftParen, `(`, 4, 23},
+		{source.TokenIdentifier, "f", 4, 24},
+		{source.TokenIdentifier, "-7", 4, 26},
+		{source.TokenRightParen, `)`, 4, 28},
+		{source.TokenIdentifier, "y", 4, 34},
+	}
+
+	logger := util.NewLogger()
+	tokenizer := NewTokenizer(&logger)
+	source_code := tokenizer.Tokenize("test", *text)
+	if !logger.IsEmpty() {
+		m, _ := logger.Next()
+		test.Fatal(m)
+	}
+	if source_code.TokenCount()-1 != len(expected) {
+		test.Fatalf("Expected %d tokens got %d", len(expected), source_code.TokenCount()-1)
+	}
+	for i, e := range expected {
+		t := source_code.Token(source.TokenId(i))
+		got := source_code.Lexeme(source.TokenId(i))
+		if e.tag != t.Tag || e.lexeme != got || e.line != t.Line || e.col != t.Col {
+			test.Errorf("Expected [%d %s %d:%d] got [%d %s %d:%d]",
+				e.tag, e.lexeme, e.line, e.col,
+				t.Tag, got, t.Line, t.Col,
+			)
+		}
+	}
+}
+
+func TestTokenizerUnterminatedComment(test *testing.T) {
+	text := utf8string.NewString("x\n  {- {- -} y")
+	logger := util.NewLogger()
+	tokenizer := NewTokenizer(&logger)
+	tokenizer.Tokenize("test", *text)
+	m, ok := logger.Next()
+	if !ok {
+		test.Fatal("Expected error on unterminated comment")
+	}
+	if !strings.HasPrefix(m.String(), "Fatal at test:2:2") {
+		test.Errorf("Expected error to point at comment start, got %s", m)
+	}
+}
+
+func TestParseModule(test *testing.T) {
+	text := `
+def K = λx y.x
+def S = λx y z.
+    x z (y z)
+-- comment between definitions
+def I = S K K
+-- layout doesn't apply inside parens
+def P = (K
+I)
+I
+  a`
+	logger := util.NewLogger()
+	tokenizer := NewTokenizer(&logger)
+	source_code := tokenizer.Tokenize("test", *utf8string.NewString(text))
+	parser := NewParserWithMode(&logger, ModeNonStrict)
+	m := parser.ParseModule(source_code)
+	if m, ok := logger.Next(); ok {
+		test.Fatal(m)
+	}
+
+	expected := [...]struct{ name, value string }{
+		{"K", `(λ x(λ y x))`},
+		{"S", `(λ x(λ y(λ z(( x z)( y z)))))`},
+		{"I", `(( S K) K)`},
+		{"P", `( K I)`},
+	}
+	if len(m.Definitions) != len(expected) {
+		test.Fatalf("Expected %d definitions got %d", len(expected), len(m.Definitions))
+	}
+	for i, e := range expected {
+		d := m.Definitions[i]
+		got := ast.Print(source_code, m.Tree, d.Value)
+		if d.Name != e.name || got != e.value {
+			test.Errorf("Expected [%s %s] got [%s %s]", e.name, e.value, d.Name, got)
+		}
+	}
+	if got := ast.Print(source_code, m.Tree, m.Main); got != `( I a)` {
+		test.Errorf("Unexpected main %s", got)
+	}
+	if got := source_code.Text(m.Definitions[1].Token, m.Definitions[1].End); !strings.HasPrefix(got, "def S = λx y z.\n    x z (y z)\n") {
+		test.Errorf("Unexpected definition text %q", got)
+	}
+	if got := source_code.Text(m.Definitions[3].Start, m.Definitions[3].End); got != "(K\nI)\n" {
+		test.Errorf("Unexpected value text %q", got)
+	}
+	if got := source_code.Text(m.MainStart, source.TokenId(source_code.TokenCount()-1)); got != "I\n  a" {
+		test.Errorf("Unexpected main text %q", got)
+	}
+}
+
+func TestParseModuleErrors(test *testing.T) {
+	for _, text := range [...]string{"def f = a\ndef f = b", "def = a", "def f a", "a\ndef f = a", "f\nx", "def id foo λx.x"} {
+		logger := util.NewLogger()
+		tokenizer := NewTokenizer(&logger)
+		source_code := tokenizer.Tokenize("test", *utf8string.NewString(text))
+		parser := NewParserWithMode(&logger, ModeNonStrict)
+		parser.ParseModule(source_code)
+		if logger.IsEmpty() {
+			test.Errorf("Expected error on %q", text)
+		}
+	}
+}
+
+func TestTokenizerStringsAndQualifiedNames(test *testing.T) {
+	text := utf8string.NewString(`import "lib/num.lc" as N
+λx y.N.Succ x.y 42 -1 x1`)
+	expected := [...]struct {
+		tag    source.TokenId
+		lexeme string
+	}{
+		{source.TokenIdentifier, "import"},
+		{source.TokenString, `"lib/num.lc"`},
+		{source.TokenIdentifier, "as"},
+		{source.TokenIdentifier, "N"},
+		{source.TokenLambda, `λ`},
+		{source.TokenIdentifier, "x"},
+		{source.TokenIdentifier, "y"},
+		{source.TokenDot, `.`},
+		{source.TokenIdentifier, "N.Succ"},
+		{source.TokenIdentifier, "x.y"},
+		{source.TokenNumber, "42"},
+		{source.TokenIdentifier, "-1"},
+		{source.TokenIdentifier, "x1"},
+	}
+
+	logger := util.NewLogger()
+	tokenizer := NewTokenizer(&logger)
+	source_code := tokenizer.Tokenize("test", *text)
+	if source_code.TokenCount()-1 != len(expected) {
+		test.Fatalf("Expected %d tokens got %d", len(expected), source_code.TokenCount()-1)
+	}
+	for i, e := range expected {
+		t := source_code.Token(source.TokenId(i))
+		got := source_code.Lexeme(source.TokenId(i))
+		if e.tag != t.Tag || e.lexeme != got {
+			test.Errorf("Expected [%d %s] got [%d %s]", e.tag, e.lexeme, t.Tag, got)
+		}
+	}
+}
+
+func TestParseModuleImports(test *testing.T) {
+	text := `import "bool.lc"
+import "lib/num.lc" as N
+def Two = N.Succ (N.Succ N.Zero)
+N.IsZero Two`
+	logger := util.NewLogger()
+	tokenizer := NewTokenizer(&logger)
+	source_code := tokenizer.Tokenize("test", *utf8string.NewString(text))
+	parser := NewParserWithMode(&logger, ModeNonStrict)
+	m := parser.ParseModule(source_code)
+	if m, ok := logger.Next(); ok {
+		test.Fatal(m)
+	}
+
+	if len(m.Imports) != 2 ||
+		m.Imports[0].Path != "bool.lc" || m.Imports[0].Alias != "" ||
+		m.Imports[1].Path != "lib/num.lc" || m.Imports[1].Alias != "N" {
+		test.Fatalf("Unexpected imports %v", m.Imports)
+	}
+	if got := ast.Print(source_code, m.Tree, m.Definitions[0].Value); got != `( N.Succ( N.Succ N.Zero))` {
+		test.Errorf("Unexpected definition %s", got)
+	}
+	if got := source_code.Lexeme(m.MainStart); got != "N.IsZero" {
+		test.Errorf("Expected main to start at N.IsZero, got %s", got)
+	}
+}
@@ -32,6 +32,18 @@ func (s SourceCode) Lexeme(id TokenId) string {
 	return s.text.Slice(int(t.Start), int(t.End))
 }
 
+// Text returns source text starting at token from up to (but not including) token to,
+// together with comments and spaces in between
+func (s SourceCode) Text(from, to TokenId) string {
+	offset := func(id TokenId) int {
+		if s.Token(id).Tag == TokenEof {
+			return s.text.RuneCount()
+		}
+		return s.Token(id).Start
+	}
+	return s.text.Slice(offset(from), offset(to))
+}
+
 func (s SourceCode) Filename() string {
 	return s.filename
 }
@@ -54,3 +66,44 @@ func (s SourceCode) TraceToken(tag TokenId, lexeme string, line int, col int) st
 	}
 	return str
 }
+
+// Builder makes source code out of other sources and made up lexemes, tokens of
+// appended sources keep their locations
+type Builder struct {
+	filename string
+	text     []rune
+	tokens   []Token
+}
+
+func NewBuilder(filename string) Builder {
+	return Builder{filename: filename}
+}
+
+// Append copies all tokens of src and returns offset that should be added to
+// token ids of src to get ids of their copies
+func (b *Builder) Append(src SourceCode) TokenId {
+	offset := TokenId(len(b.tokens))
+	text_offset := len(b.text)
+	b.text = append(b.text, []rune(src.text.String())...)
+	for _, t := range src.tokens {
+		if t.Tag != TokenEof {
+			t.Start += text_offset
+			t.End += text_offset
+		}
+		b.tokens = append(b.tokens, t)
+	}
+	return offset
+}
+
+// AddToken adds token with lexeme that doesn't occur in any source text
+func (b *Builder) AddToken(tag TokenId, lexeme string, line, col int) TokenId {
+	start := len(b.text)
+	b.text = append(b.text, []rune(lexeme)...)
+	b.tokens = append(b.tokens, NewToken(tag, start, len(b.text), line, col))
+	return TokenId(len(b.tokens) - 1)
+}
+
+func (b Builder) SourceCode() SourceCode {
+	tokens := append(append([]Token{}, b.tokens...), NewTokenEof())
+	return NewSourceCode(b.filename, *utf8string.NewString(string(b.text)), tokens)
+}
@@ -15,6 +15,8 @@ const (
 	TokenLambda
 	TokenLeftParen
 	TokenRightParen
+	TokenString
+	TokenNumber
 )
 
 const (
@@ -23,8 +25,29 @@ const (
 	TokenLambdaRune          rune = 'λ'
 	TokenLeftParenRune       rune = '('
 	TokenRightParenRune      rune = ')'
+	TokenStringRune          rune = '"'
 )
 
+// Comments are skipped by tokenizer, block comments can be nested
+const (
+	LineComment       = "--"
+	LineCommentHash   = "#"
+	BlockCommentOpen  = "{-"
+	BlockCommentClose = "-}"
+)
+
+// Keywords are identifiers that can't be used as names
+var Keywords = [...]string{"import", "as", "def", "let", "letrec", "and", "=", "in"}
+
+func IsKeyword(lexeme string) bool {
+	for _, k := range Keywords {
+		if k == lexeme {
+			return true
+		}
+	}
+	return false
+}
+
 type Token struct {
 	Tag                   TokenId
 	Start, End, Line, Col int
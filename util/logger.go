@@ -27,10 +27,11 @@ type log_message struct {
 
 func NewMessage(type_ message_type, line, col int, filename, message string) log_message {
 	return log_message{
-		type_:   type_,
-		line:    line,
-		col:     col,
-		message: message,
+		type_:    type_,
+		line:     line,
+		col:      col,
+		filename: filename,
+		message:  message,
 	}
 }
 